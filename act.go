@@ -2,18 +2,36 @@ package cron
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/dgraph-io/ristretto"
-	"github.com/icco/cron/code"
-	"github.com/icco/cron/gaudit"
-	"github.com/icco/cron/goodreads"
-	"github.com/icco/cron/pinboard"
+	"github.com/google/uuid"
+	"github.com/icco/cron/deadletter"
+	"github.com/icco/cron/dedupe"
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/metrics"
+	"github.com/icco/cron/retry"
+	"github.com/icco/cron/runs"
+	"github.com/icco/cron/secrets"
 	"github.com/icco/cron/shared"
-	"github.com/icco/cron/spider"
-	"github.com/icco/cron/stats"
-	"github.com/icco/cron/tweets"
+	"github.com/icco/cron/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	// Packages with jobs register them on import.
+	_ "github.com/icco/cron/code"
+	_ "github.com/icco/cron/gaudit"
+	_ "github.com/icco/cron/goodreads"
+	_ "github.com/icco/cron/pinboard"
+	_ "github.com/icco/cron/spider"
+	_ "github.com/icco/cron/stats"
+	_ "github.com/icco/cron/tweets"
+	_ "github.com/icco/cron/updater"
 )
 
 const (
@@ -22,136 +40,455 @@ const (
 
 	// Service is the name of this service.
 	Service = "cron"
+
+	// DefaultDeadLetterAfter is how many deliveries of a message have to fail
+	// before it is dead lettered, unless Config.DeadLetterAfter says otherwise.
+	DefaultDeadLetterAfter = 5
+
+	// failureTTL is how long a message's failed deliveries are remembered
+	// after the last one.
+	failureTTL = 24 * time.Hour
+)
+
+var (
+	// ErrSkipped is returned by Act when a job's concurrency policy kept it
+	// from running.
+	ErrSkipped = errors.New("skipped")
+
+	// ErrDuplicate is returned by Act when a message was already handled.
+	ErrDuplicate = errors.New("duplicate message")
+
+	// ErrInFlight is returned along with ErrDuplicate when the message is
+	// still being handled by another call to Act. Its outcome isn't known
+	// yet, so the duplicate should be delivered again rather than dropped.
+	ErrInFlight = errors.New("still running")
+
+	// ErrDeadLettered is wrapped around a failure once its message has been
+	// moved to the dead letter store, meaning it should not be redelivered.
+	ErrDeadLettered = errors.New("dead lettered")
+
+	// ErrInterrupted is returned by Act when its context was canceled before
+	// the job finished, usually because the server is shutting down.
+	ErrInterrupted = errors.New("interrupted")
 )
 
+func init() {
+	jobs.Register(jobs.New(
+		"minute",
+		"Logs a heartbeat.",
+		func(ctx context.Context, cfg *jobs.Config) error {
+			cfg.Log.Info("heartbeat")
+			return nil
+		},
+		jobs.WithSchedule("* * * * *"),
+	))
+}
+
 // Config is our base act config struct.
 type Config struct {
 	shared.Config
 
-	Cache *ristretto.Cache
+	Cache   *ristretto.Cache
+	Secrets secrets.Provider
+
+	// Runs records every call to Act. Nil means runs are not recorded.
+	Runs runs.Store
+
+	// Dedupe drops messages whose ID was already handled. Nil means every
+	// message is handled.
+	Dedupe *dedupe.Window
+
+	// DeadLetters keeps messages whose job failed after every attempt. Nil
+	// means failures are only logged.
+	DeadLetters deadletter.Store
+
+	// DeadLetterAfter is how many deliveries of a message have to fail with
+	// a retryable error before it is dead lettered. Until then the error is
+	// returned so the message is delivered again. Permanent failures and
+	// messages without an ID are dead lettered the first time. Zero means
+	// DefaultDeadLetterAfter.
+	DeadLetterAfter int
+
+	limits limiter
+
+	mu     sync.Mutex
+	active map[string]*Message
+
+	// handling holds the IDs of messages Act is handling.
+	handling map[string]bool
+
+	// failures counts the failed deliveries of each message by ID.
+	failures map[string]failures
 }
 
-// Act takes a job and calls a sub project to do work.
-func (cfg *Config) Act(ctx context.Context, job string) error {
-	gqlToken := os.Getenv("GQL_TOKEN")
-	if gqlToken == "" {
-		return fmt.Errorf("GQL_TOKEN is unset")
-	}
+// failures is how many times a message failed, and when it last did.
+type failures struct {
+	n    int
+	last time.Time
+}
 
-	twitterAuth := &tweets.TwitterAuth{
-		ConsumerKey:    os.Getenv("TWITTER_CONSUMER_KEY"),
-		ConsumerSecret: os.Getenv("TWITTER_CONSUMER_SECRET"),
-		AccessToken:    os.Getenv("TWITTER_ACCESS_TOKEN"),
-		AccessSecret:   os.Getenv("TWITTER_ACCESS_SECRET"),
+// DataDir is where file backed stores live. It is $CRON_DATA_DIR, or a
+// directory in the system temp dir if that is unset.
+func DataDir() string {
+	if d := os.Getenv("CRON_DATA_DIR"); d != "" {
+		return d
 	}
 
-	pinboardToken := os.Getenv("PINBOARD_TOKEN")
-	if pinboardToken == "" {
-		return fmt.Errorf("PINBOARD_TOKEN is unset")
+	return filepath.Join(os.TempDir(), Service)
+}
+
+// Act takes a message and calls a sub project to do the work it asks for.
+// Every call is recorded as a run, except for duplicate messages which return
+// ErrDuplicate without running anything.
+func (cfg *Config) Act(ctx context.Context, msg *Message) (err error) {
+	ctx, span := tracing.Start(ctx, "cron.Act", attribute.String("cron.job", msg.Job), attribute.String("cron.message_id", msg.ID))
+	defer func() {
+		tracing.Error(span, err)
+		span.End()
+	}()
+
+	if cfg.Dedupe != nil && msg.ID != "" {
+		if err := cfg.claim(msg); err != nil {
+			cfg.Log.Warnw("dropping duplicate message", "id", msg.ID, "job", msg.Job, zap.Error(err))
+			return err
+		}
+		defer cfg.handled(msg.ID)
 	}
 
-	goodreadsToken := os.Getenv("GOODREADS_TOKEN")
-	if goodreadsToken == "" {
-		return fmt.Errorf("GOODREADS_TOKEN is unset")
+	rec := cfg.recorder(msg)
+	run := &runs.Run{
+		ID:        uuid.NewString(),
+		Job:       msg.Job,
+		Args:      msg.Args,
+		MessageID: msg.ID,
+		Status:    runs.Running,
+		Started:   cfg.Now(),
+		DryRun:    rec != nil,
 	}
+	cfg.saveRun(ctx, run)
+	cfg.track(run.ID, msg)
+	span.SetAttributes(attribute.String("cron.run_id", run.ID))
 
-	githubToken := os.Getenv("GITHUB_TOKEN")
-	if githubToken == "" {
-		return fmt.Errorf("GITHUB_TOKEN is unset")
+	// Only registered jobs get their own label, so made up names can't blow
+	// up the number of series.
+	label := "unknown"
+	if _, ok := jobs.Get(msg.Job); ok {
+		label = msg.Job
 	}
+	metrics.JobsInFlight.WithLabelValues(label).Inc()
+	err = cfg.act(ctx, msg, run, rec)
+	finished := cfg.Now()
+	metrics.JobsInFlight.WithLabelValues(label).Dec()
+	metrics.JobDuration.WithLabelValues(label).Observe(finished.Sub(run.Started).Seconds())
 
-	switch job {
-	case "test":
-		v, err := stats.GetAssetMix(ctx)
-		if err != nil {
-			return err
-		}
-		cfg.Log.Warnf("%d, %+v", v, err)
-	case "minute":
-		cfg.Log.Info("heartbeat")
-	case "github-audit":
-		c := &gaudit.Config{
-			Config:      shared.Config{Log: cfg.Log},
-			User:        "icco",
-			GithubToken: githubToken,
+	if !cfg.untrack(run.ID) {
+		metrics.JobRuns.WithLabelValues(label, string(runs.Interrupted)).Inc()
+		// Interrupt already recorded this run and dead lettered its message.
+		if cfg.DeadLetters != nil {
+			return fmt.Errorf("%w: %w", ErrDeadLettered, ErrInterrupted)
 		}
+		return ErrInterrupted
+	}
 
-		if err := c.CheckRepos(ctx); err != nil {
-			return err
-		}
-	case "spider":
-		spider.Crawl(ctx, &spider.Config{
-			Config: shared.Config{Log: cfg.Log},
-			URL:    "https://writing.natwelch.com/",
-		})
-	case "user-tweets":
-		t := tweets.Twitter{
-			Config:       shared.Config{Log: cfg.Log},
-			TwitterAuth:  twitterAuth,
-			GraphQLToken: gqlToken,
-		}
+	run.Finished = finished
+	if rec != nil {
+		sum := rec.Summary()
+		run.Writes = &sum
+		cfg.Log.Infow("dry run finished", "job", msg.Job, "run", run.ID, "writes", sum)
+	}
 
-		if err := t.CacophonyCron(ctx); err != nil {
-			return err
+	switch {
+	case err == nil:
+		run.Status = runs.Succeeded
+		cfg.forgetFailures(msg.ID)
+	case errors.Is(err, ErrSkipped):
+		run.Status = runs.Skipped
+		run.Reason = err.Error()
+		cfg.Log.Warnw("skipped run", "job", msg.Job, "reason", run.Reason)
+	default:
+		run.Status = runs.Failed
+		if ctx.Err() != nil {
+			run.Status = runs.Interrupted
+			err = fmt.Errorf("%w: %w", ErrInterrupted, err)
 		}
+		run.Error = err.Error()
+		run.ErrorClass = retry.ClassOf(err).String()
 
-		if err := t.SaveUserTweets(ctx); err != nil {
-			return err
-		}
-	case "pinboard":
-		p := &pinboard.Pinboard{
-			Config:       shared.Config{Log: cfg.Log},
-			Token:        pinboardToken,
-			GraphQLToken: gqlToken,
-		}
-		err := p.UpdatePins(ctx)
-		if err != nil {
-			return err
-		}
-	case "random-tweets":
-		t := &tweets.Twitter{
-			Config:       shared.Config{Log: cfg.Log},
-			TwitterAuth:  twitterAuth,
-			GraphQLToken: gqlToken,
+		// A dry run changed nothing, so there is nothing to replay.
+		if !run.DryRun && cfg.lastDelivery(msg, err) && cfg.deadLetter(ctx, msg, run) {
+			err = fmt.Errorf("%w: %w", ErrDeadLettered, err)
+		} else if cfg.Dedupe != nil && msg.ID != "" {
+			// Let a redelivery of this message try again.
+			cfg.Dedupe.Release(msg.ID)
 		}
-		err := t.CacheRandomTweets(ctx)
-		if err != nil {
-			return err
+	}
+	cfg.saveRun(ctx, run)
+	metrics.JobRuns.WithLabelValues(label, string(run.Status)).Inc()
+	span.SetAttributes(attribute.String("cron.status", string(run.Status)), attribute.Int("cron.attempts", run.Attempts))
+
+	return err
+}
+
+// claim claims msg.ID in Dedupe. It returns ErrDuplicate if the message was
+// already handled, along with ErrInFlight if it still is.
+func (cfg *Config) claim(msg *Message) error {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	if !cfg.Dedupe.Claim(msg.ID) {
+		if cfg.handling[msg.ID] {
+			return fmt.Errorf("%w: %w: message %q", ErrDuplicate, ErrInFlight, msg.ID)
 		}
-	case "goodreads":
-		g := &goodreads.Goodreads{
-			Config:       shared.Config{Log: cfg.Log},
-			Token:        goodreadsToken,
-			GraphQLToken: gqlToken,
+		return fmt.Errorf("%w: message %q", ErrDuplicate, msg.ID)
+	}
+
+	if cfg.handling == nil {
+		cfg.handling = map[string]bool{}
+	}
+	cfg.handling[msg.ID] = true
+	return nil
+}
+
+// handled is called once Act is done with the message claimed as id.
+func (cfg *Config) handled(id string) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	delete(cfg.handling, id)
+}
+
+func (cfg *Config) track(id string, msg *Message) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	if cfg.active == nil {
+		cfg.active = map[string]*Message{}
+	}
+	cfg.active[id] = msg
+}
+
+// untrack returns false if the run was no longer being tracked, because
+// Interrupt took it.
+func (cfg *Config) untrack(id string) bool {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	_, ok := cfg.active[id]
+	delete(cfg.active, id)
+	return ok
+}
+
+// Active is the number of calls to Act that have not returned.
+func (cfg *Config) Active() int {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	return len(cfg.active)
+}
+
+// Interrupt records every run that is still going as interrupted and dead
+// letters its message so it can be replayed. It is for shutting down, when
+// jobs that ignored cancellation are about to be killed, and returns how many
+// runs it recorded.
+func (cfg *Config) Interrupt(ctx context.Context) int {
+	cfg.mu.Lock()
+	active := cfg.active
+	cfg.active = nil
+	cfg.mu.Unlock()
+
+	for id, msg := range active {
+		dry := msg.DryRun || cfg.DryRun != nil
+		run := &runs.Run{
+			ID:        id,
+			Job:       msg.Job,
+			Args:      msg.Args,
+			MessageID: msg.ID,
+			Status:    runs.Interrupted,
+			Error:     ErrInterrupted.Error(),
+			Finished:  cfg.Now(),
+			DryRun:    dry,
 		}
-		err := g.UpsertBooks(ctx)
-		if err != nil {
-			return err
+		if cfg.Runs != nil {
+			// Keep the start time that was recorded when the run began.
+			if r, err := cfg.Runs.Get(ctx, id); err == nil {
+				run.Started = r.Started
+				run.Attempts = r.Attempts
+			}
 		}
-	case "stats":
-		c := &stats.Config{
-			Config:       shared.Config{Log: cfg.Log},
-			GraphQLToken: gqlToken,
-			OWMKey:       os.Getenv("OPEN_WEATHER_MAP_KEY"),
+		cfg.saveRun(ctx, run)
+		if !dry {
+			cfg.deadLetter(ctx, msg, run)
 		}
+	}
 
-		if err := c.UpdateOften(ctx); err != nil {
-			return err
-		}
-	case "code":
-		c := &code.Config{
-			Config:      shared.Config{Log: cfg.Log},
-			User:        "icco",
-			GithubToken: githubToken,
-			Cache:       cfg.Cache,
-		}
+	return len(active)
+}
 
-		if err := c.FetchAndSaveCommits(ctx); err != nil {
-			return err
+// lastDelivery counts a failed delivery of msg and reports whether it should
+// be the last, because err is permanent or an interruption, msg has no ID to
+// count by, or it has now failed DeadLetterAfter times.
+func (cfg *Config) lastDelivery(msg *Message, err error) bool {
+	if msg.ID == "" || retry.ClassOf(err) == retry.ClassPermanent || errors.Is(err, ErrInterrupted) {
+		return true
+	}
+
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	now := cfg.Now()
+	// Pub/Sub gives up on messages eventually, so don't count them forever.
+	for id, f := range cfg.failures {
+		if now.Sub(f.last) > failureTTL {
+			delete(cfg.failures, id)
 		}
-	default:
-		return fmt.Errorf("unknown job type: %q", job)
+	}
+
+	after := cfg.DeadLetterAfter
+	if after < 1 {
+		after = DefaultDeadLetterAfter
+	}
+	f := cfg.failures[msg.ID]
+	f.n++
+	f.last = now
+	if f.n >= after {
+		delete(cfg.failures, msg.ID)
+		return true
+	}
+	if cfg.failures == nil {
+		cfg.failures = map[string]failures{}
+	}
+	cfg.failures[msg.ID] = f
+	cfg.Log.Warnw("message failed, leaving it to be delivered again", "id", msg.ID, "job", msg.Job, "failures", f.n, "dead_letter_after", after)
+
+	return false
+}
+
+// forgetFailures clears the failed deliveries counted for a message.
+func (cfg *Config) forgetFailures(id string) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	delete(cfg.failures, id)
+}
+
+// deadLetter moves a failed message to the dead letter store. It returns true
+// if the message was stored.
+func (cfg *Config) deadLetter(ctx context.Context, msg *Message, run *runs.Run) bool {
+	if cfg.DeadLetters == nil {
+		return false
+	}
+
+	e := &deadletter.Entry{
+		ID:        uuid.NewString(),
+		MessageID: msg.ID,
+		Job:       msg.Job,
+		Args:      msg.Args,
+		RunID:     run.ID,
+		Attempts:  run.Attempts,
+		LastError: run.Error,
+		Added:     cfg.Now(),
+	}
+	if err := cfg.DeadLetters.Add(context.WithoutCancel(ctx), e); err != nil {
+		cfg.Log.Errorw("could not dead letter message", "entry", e, zap.Error(err))
+		return false
+	}
+
+	cfg.Log.Errorw("dead lettered message", "entry", e)
+	return true
+}
+
+// Replay removes a dead lettered message from the store and returns it, ready
+// to be passed to Act again.
+func (cfg *Config) Replay(ctx context.Context, id string) (*Message, error) {
+	if cfg.DeadLetters == nil {
+		return nil, deadletter.ErrNotFound
+	}
+
+	e, err := cfg.DeadLetters.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.DeadLetters.Remove(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return &Message{Job: e.Job, Args: e.Args}, nil
+}
+
+func (cfg *Config) saveRun(ctx context.Context, r *runs.Run) {
+	if cfg.Runs == nil {
+		return
+	}
+
+	// Recording history should never stop a job from running.
+	if err := cfg.Runs.Save(context.WithoutCancel(ctx), r); err != nil {
+		cfg.Log.Errorw("could not save run", "run", r, zap.Error(err))
+	}
+}
+
+// recorder returns where the writes of a run of msg go, or nil if it is not a
+// dry run. Every run is a dry run when cfg.DryRun is set.
+func (cfg *Config) recorder(msg *Message) *shared.Recorder {
+	if cfg.DryRun != nil {
+		return cfg.DryRun
+	}
+	if msg.DryRun {
+		return &shared.Recorder{}
 	}
 
 	return nil
 }
+
+// act runs the job msg asks for, retrying it according to the job's policy.
+// Errors that retrying can't fix are marked permanent. Writes go to rec if it
+// is set.
+func (cfg *Config) act(ctx context.Context, msg *Message, run *runs.Run, rec *shared.Recorder) error {
+	j, ok := jobs.Get(msg.Job)
+	if !ok {
+		return retry.Permanent(fmt.Errorf("unknown job type: %q", msg.Job))
+	}
+
+	if err := jobs.ValidateArgs(j, msg.Args); err != nil {
+		return retry.Permanent(err)
+	}
+
+	release, err := cfg.limits.acquire(ctx, j)
+	if err != nil {
+		return retry.Permanent(err)
+	}
+	defer release()
+
+	vals, err := secrets.Resolve(ctx, cfg.Secrets, j.Secrets())
+	var missing *secrets.MissingError
+	if errors.As(err, &missing) {
+		return retry.Permanent(fmt.Errorf("resolve secrets for %q: %w", msg.Job, err))
+	}
+	if err != nil {
+		return fmt.Errorf("resolve secrets for %q: %w", msg.Job, err)
+	}
+
+	jcfg := &jobs.Config{
+		Config:  cfg.Config,
+		Project: GCPProject,
+		Cache:   cfg.Cache,
+		Secrets: vals,
+		Args:    msg.Args,
+	}
+	jcfg.DryRun = rec
+
+	return retry.Do(ctx, j.Retry(), func(ctx context.Context, attempt int) error {
+		run.Attempts = attempt
+		if attempt > 1 {
+			cfg.Log.Infow("retrying job", "job", msg.Job, "attempt", attempt, "run", run.ID)
+			cfg.saveRun(ctx, run)
+		}
+
+		err := j.Run(ctx, jcfg)
+		if err != nil {
+			cfg.Log.Warnw("job attempt failed", "job", msg.Job, "attempt", attempt, "class", retry.ClassOf(err).String(), zap.Error(err))
+		}
+
+		return err
+	})
+}
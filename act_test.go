@@ -0,0 +1,250 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/icco/cron/deadletter"
+	"github.com/icco/cron/dedupe"
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/retry"
+	"github.com/icco/cron/runs"
+	"github.com/icco/cron/shared"
+	"go.uber.org/zap"
+)
+
+func testConfig(t *testing.T) *Config {
+	t.Helper()
+
+	dl, err := deadletter.OpenFile(filepath.Join(t.TempDir(), "deadletters.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dl.Close() })
+
+	return &Config{
+		Config:      shared.Config{Log: zap.NewNop().Sugar()},
+		Runs:        runs.NewMemory(),
+		DeadLetters: dl,
+	}
+}
+
+func TestActInterrupted(t *testing.T) {
+	started := make(chan struct{})
+	jobs.Register(jobs.New("test-cancelable", "", func(ctx context.Context, cfg *jobs.Config) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	cfg := testConfig(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- cfg.Act(ctx, &Message{Job: "test-cancelable"}) }()
+
+	<-started
+	cancel()
+	err := <-done
+	if !errors.Is(err, ErrInterrupted) || !errors.Is(err, ErrDeadLettered) {
+		t.Fatalf("expected an interrupted, dead lettered error, got %+v", err)
+	}
+
+	list, err := cfg.Runs.List(context.Background(), runs.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Status != runs.Interrupted || list[0].Attempts != 1 {
+		t.Errorf("expected one interrupted run after one attempt, got %+v", list)
+	}
+
+	entries, err := cfg.DeadLetters.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Job != "test-cancelable" {
+		t.Errorf("expected the message to be dead lettered, got %+v", entries)
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	jobs.Register(jobs.New("test-stubborn", "", func(ctx context.Context, cfg *jobs.Config) error {
+		close(started)
+		<-finish
+		return nil
+	}))
+
+	cfg := testConfig(t)
+	done := make(chan error)
+	go func() {
+		done <- cfg.Act(context.Background(), &Message{ID: "m1", Job: "test-stubborn", Args: jobs.Args{}})
+	}()
+
+	<-started
+	if n := cfg.Active(); n != 1 {
+		t.Fatalf("Active() = %d, want 1", n)
+	}
+	if n := cfg.Interrupt(context.Background()); n != 1 {
+		t.Fatalf("Interrupt() = %d, want 1", n)
+	}
+
+	list, err := cfg.Runs.List(context.Background(), runs.Filter{Status: runs.Interrupted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].MessageID != "m1" || list[0].Started.IsZero() {
+		t.Errorf("expected the run to be recorded as interrupted, got %+v", list)
+	}
+
+	// The job finishing after being interrupted must not be recorded twice.
+	close(finish)
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrInterrupted) {
+			t.Errorf("expected ErrInterrupted, got %+v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Act did not return")
+	}
+
+	entries, err := cfg.DeadLetters.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected one dead letter, got %d", len(entries))
+	}
+	if r, _ := cfg.Runs.Get(context.Background(), list[0].ID); r.Status != runs.Interrupted {
+		t.Errorf("expected run to stay interrupted, got %s", r.Status)
+	}
+}
+
+func TestActDryRun(t *testing.T) {
+	jobs.Register(jobs.New("test-dry-run", "", func(ctx context.Context, cfg *jobs.Config) error {
+		if cfg.DryRun == nil {
+			t.Error("expected a recorder")
+			return nil
+		}
+		cfg.DryRun.Record(shared.WriteHTTP, "POST https://code.natwelch.com/save", map[string]string{"sha": "abc"})
+		return errors.New("boom")
+	}, jobs.WithRetry(retry.Never)))
+
+	cfg := testConfig(t)
+	if err := cfg.Act(context.Background(), &Message{Job: "test-dry-run", Args: jobs.Args{}, DryRun: true}); err == nil {
+		t.Fatal("expected the job's error")
+	}
+
+	list, err := cfg.Runs.List(context.Background(), runs.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !list[0].DryRun || list[0].Writes == nil || list[0].Writes.Total != 1 {
+		t.Fatalf("expected a dry run with one write, got %+v", list)
+	}
+
+	entries, err := cfg.DeadLetters.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected a failed dry run not to be dead lettered, got %+v", entries)
+	}
+}
+
+func TestActClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := shared.NewFakeClock(start)
+	jobs.Register(jobs.New("test-slow", "", func(ctx context.Context, cfg *jobs.Config) error {
+		clock.Advance(5 * time.Minute)
+		return nil
+	}))
+
+	cfg := testConfig(t)
+	cfg.Clock = clock
+	if err := cfg.Act(context.Background(), &Message{Job: "test-slow"}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := cfg.Runs.List(context.Background(), runs.Filter{Job: "test-slow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !list[0].Started.Equal(start) || !list[0].Finished.Equal(start.Add(5*time.Minute)) || list[0].Duration() != 5*time.Minute {
+		t.Errorf("expected a five minute run by the fake clock, got %+v", list)
+	}
+}
+
+func TestActInFlight(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	jobs.Register(jobs.New("test-long", "", func(ctx context.Context, cfg *jobs.Config) error {
+		close(started)
+		<-finish
+		return errors.New("connection reset")
+	}, jobs.WithRetry(retry.Never)))
+
+	cfg := testConfig(t)
+	cfg.DeadLetters = nil
+	cfg.Dedupe = dedupe.NewWindow(time.Hour)
+	msg := &Message{ID: "m1", Job: "test-long", Args: jobs.Args{}}
+	done := make(chan error)
+	go func() { done <- cfg.Act(context.Background(), msg) }()
+
+	// A redelivery while the first delivery is still running must not be
+	// dropped, as the first may yet fail.
+	<-started
+	if err := cfg.Act(context.Background(), msg); !errors.Is(err, ErrDuplicate) || !errors.Is(err, ErrInFlight) {
+		t.Fatalf("expected an in flight duplicate, got %+v", err)
+	}
+
+	close(finish)
+	if err := <-done; err == nil || errors.Is(err, ErrDuplicate) {
+		t.Fatalf("expected the job's error, got %+v", err)
+	}
+
+	// Once it failed, the message can run again.
+	finish = make(chan struct{})
+	started = make(chan struct{})
+	close(finish)
+	if err := cfg.Act(context.Background(), msg); errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected a failed message to run again, got %+v", err)
+	}
+}
+
+func TestActDeadLetterAfter(t *testing.T) {
+	jobs.Register(jobs.New("test-failing", "", func(ctx context.Context, cfg *jobs.Config) error {
+		return errors.New("connection reset")
+	}, jobs.WithRetry(retry.Never)))
+	jobs.Register(jobs.New("test-invalid", "", func(ctx context.Context, cfg *jobs.Config) error {
+		return retry.Permanent(errors.New("bad input"))
+	}))
+
+	cfg := testConfig(t)
+	cfg.DeadLetterAfter = 3
+	msg := &Message{ID: "m1", Job: "test-failing", Args: jobs.Args{}}
+	for i := 1; i < cfg.DeadLetterAfter; i++ {
+		if err := cfg.Act(context.Background(), msg); err == nil || errors.Is(err, ErrDeadLettered) {
+			t.Fatalf("delivery %d: expected the error to be left for a redelivery, got %+v", i, err)
+		}
+	}
+	if err := cfg.Act(context.Background(), msg); !errors.Is(err, ErrDeadLettered) {
+		t.Fatalf("expected the message to be dead lettered on delivery %d, got %+v", cfg.DeadLetterAfter, err)
+	}
+
+	// Sending a permanent failure again won't help.
+	if err := cfg.Act(context.Background(), &Message{ID: "m2", Job: "test-invalid", Args: jobs.Args{}}); !errors.Is(err, ErrDeadLettered) {
+		t.Fatalf("expected a permanent failure to be dead lettered at once, got %+v", err)
+	}
+
+	entries, err := cfg.DeadLetters.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].MessageID != "m1" || entries[1].MessageID != "m2" {
+		t.Errorf("expected m1 and m2 to be dead lettered once each, got %+v", entries)
+	}
+}
@@ -1,27 +1,230 @@
+// Command cron runs, publishes and inspects cron's jobs, and runs the server.
 package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
+	"cloud.google.com/go/pubsub"
 	"github.com/dgraph-io/ristretto"
 	"github.com/icco/cron"
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/runs"
+	"github.com/icco/cron/scheduler"
+	"github.com/icco/cron/secrets"
+	"github.com/icco/cron/server"
 	"github.com/icco/cron/shared"
+	"github.com/icco/cron/tracing"
 	"github.com/icco/gutil/logging"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
+// Exit codes.
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitUsage  = 2
+)
+
+const usageFormat = `Usage: %[1]s <command> [flags]
+
+Commands:
+  list                                           List registered jobs.
+  run [-dry-run] <job> [-arg key=value ...]      Run a job here and print how it went.
+  publish [-dry-run] <job> [-arg key=value ...]  Publish a job to the cron Pub/Sub topic.
+  serve                                          Run the server.
+  runs [-job name] [-status status] [-limit n]   List recent runs, newest first.
+  scheduler-sync [-dry-run]                      Make Cloud Scheduler match job schedules.
+
+Run "%[1]s <command> -h" for a command's flags.
+`
+
 var (
 	log = logging.Must(logging.NewLogger(cron.Service))
 )
 
 func main() {
-	cmd := os.Args[1:]
-	if len(cmd) < 2 || cmd[0] != "send" {
-		fmt.Printf("Usage: $ %s send message", os.Args[0])
-		return
+	os.Exit(dispatch(context.Background(), os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// dispatch runs the command in args and returns the exit code.
+func dispatch(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "list":
+		return list(stdout)
+	case "run":
+		return runJob(ctx, args, stdout, stderr)
+	case "publish":
+		return publish(ctx, args, stdout, stderr)
+	case "serve":
+		if err := server.Serve(ctx); err != nil {
+			log.Errorw("could not serve", zap.Error(err))
+			return exitFailed
+		}
+		return exitOK
+	case "runs":
+		return listRuns(ctx, args, stdout, stderr)
+	case "scheduler-sync":
+		return schedulerSync(ctx, args, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		usage(stdout)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n", cmd)
+		usage(stderr)
+		return exitUsage
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, usageFormat, filepath.Base(os.Args[0]))
+}
+
+// newFlagSet returns a flag set for a command that reports errors instead of
+// exiting.
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// parseError returns the exit code for an error parsing flags.
+func parseError(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+
+	return exitUsage
+}
+
+// argFlag collects -arg key=value flags into job arguments.
+type argFlag jobs.Args
+
+func (a argFlag) String() string {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k+"="+a[k])
+	}
+	sort.Strings(keys)
+
+	return strings.Join(keys, ",")
+}
+
+func (a argFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("%q is not key=value", s)
+	}
+	a[k] = v
+
+	return nil
+}
+
+// jobFlags are the flags of commands that take a job.
+type jobFlags struct {
+	fs     *flag.FlagSet
+	args   argFlag
+	dryRun *bool
+}
+
+func newJobFlags(name string, stderr io.Writer) *jobFlags {
+	f := &jobFlags{fs: newFlagSet(name, stderr), args: argFlag{}}
+	f.fs.Var(f.args, "arg", "an argument for the job as key=value, may be repeated")
+	f.dryRun = f.fs.Bool("dry-run", false, "fetch and transform as usual, but record the writes the job would make instead of making them")
+	f.fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s %s [flags] <job> [flags]\n", filepath.Base(os.Args[0]), name)
+		f.fs.PrintDefaults()
+	}
+
+	return f
+}
+
+// parse parses args like "[flags] job [flags]" into a message for a
+// registered job with valid arguments.
+func (f *jobFlags) parse(args []string) (*cron.Message, error) {
+	if err := f.fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if f.fs.NArg() == 0 {
+		f.fs.Usage()
+		return nil, errors.New("a job is required")
+	}
+
+	name := f.fs.Arg(0)
+	if err := f.fs.Parse(f.fs.Args()[1:]); err != nil {
+		return nil, err
+	}
+	if f.fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments %q, pass job arguments with -arg key=value", f.fs.Args())
+	}
+
+	j, ok := jobs.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown job %q, see the list command", name)
+	}
+	msg := &cron.Message{Job: name, Args: jobs.Args(f.args), DryRun: *f.dryRun}
+	if err := jobs.ValidateArgs(j, msg.Args); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func list(stdout io.Writer) int {
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	for _, j := range jobs.All() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", j.Name(), j.Schedule(), j.Description())
+	}
+	w.Flush()
+
+	return exitOK
+}
+
+// lastRun is a runs.Store that remembers the last run saved to it.
+type lastRun struct {
+	runs.Store
+
+	mu  sync.Mutex
+	run *runs.Run
+}
+
+func (l *lastRun) Save(ctx context.Context, r *runs.Run) error {
+	l.mu.Lock()
+	cp := *r
+	l.run = &cp
+	l.mu.Unlock()
+
+	return l.Store.Save(ctx, r)
+}
+
+// runJob runs a job in this process and prints how it went.
+func runJob(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	f := newJobFlags("run", stderr)
+	msg, err := f.parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		fmt.Fprintln(stderr, err)
+		return exitUsage
 	}
 
 	cache, err := ristretto.NewCache(&ristretto.Config{
@@ -30,15 +233,262 @@ func main() {
 		BufferItems: 64,      // Number of keys per Get buffer.
 	})
 	if err != nil {
-		log.Fatalw("could not create cache", zap.Error(err))
+		log.Errorw("could not create cache", zap.Error(err))
+		return exitFailed
 	}
+	sp, err := secrets.FromEnv(ctx, cron.GCPProject)
+	if err != nil {
+		log.Errorw("could not create secret provider", zap.Error(err))
+		return exitFailed
+	}
+
+	// The server compacts runs.jsonl by replacing it, which would lose the
+	// saves of another process, so runs from here have a file of their own.
+	rs, err := runs.OpenFile(filepath.Join(cron.DataDir(), "cli-runs.jsonl"))
+	if err != nil {
+		log.Errorw("could not open run store", zap.Error(err))
+		return exitFailed
+	}
+	defer rs.Close()
+	last := &lastRun{Store: rs}
+
+	hc, err := shared.NewHTTP(log)
+	if err != nil {
+		log.Errorw("could not create http client", zap.Error(err))
+		return exitFailed
+	}
+	hc.Base = tracing.Transport(nil)
+
 	cfg := &cron.Config{
-		Config: shared.Config{Log: log},
-		Cache:  cache,
+		Config:  shared.Config{Log: log, HTTP: hc},
+		Cache:   cache,
+		Secrets: sp,
+		Runs:    last,
+	}
+	if msg.DryRun {
+		cfg.DryRun = &shared.Recorder{}
 	}
 
-	if err := cfg.Act(context.Background(), strings.Join(cmd[1:], " ")); err != nil {
-		log.Errorw("could not act", zap.Error(err))
-		return
+	shutdown, err := tracing.Setup(ctx, cron.Service)
+	if err != nil {
+		log.Errorw("could not set up tracing", zap.Error(err))
+		return exitFailed
 	}
+	defer shutdown(ctx)
+
+	err = cfg.Act(ctx, msg)
+	if last.run != nil {
+		printRun(stdout, last.run)
+	} else if err != nil {
+		fmt.Fprintf(stdout, "%s failed: %v\n", msg.Job, err)
+	}
+	if cfg.DryRun != nil {
+		printSummary(stdout, cfg.DryRun.Summary())
+	}
+
+	if err != nil && !errors.Is(err, cron.ErrSkipped) {
+		return exitFailed
+	}
+
+	return exitOK
+}
+
+// printRun prints the outcome of a run for people.
+func printRun(stdout io.Writer, r *runs.Run) {
+	w := tabwriter.NewWriter(stdout, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(w, "job:\t%s\n", r.Job)
+	fmt.Fprintf(w, "run:\t%s\n", r.ID)
+	fmt.Fprintf(w, "status:\t%s\n", r.Status)
+	if r.Attempts > 0 {
+		fmt.Fprintf(w, "attempts:\t%d\n", r.Attempts)
+	}
+	fmt.Fprintf(w, "took:\t%s\n", r.Duration().Round(time.Millisecond))
+	if r.Reason != "" {
+		fmt.Fprintf(w, "reason:\t%s\n", r.Reason)
+	}
+	if r.Error != "" {
+		fmt.Fprintf(w, "error:\t%s (%s)\n", r.Error, r.ErrorClass)
+	}
+	w.Flush()
+}
+
+// printSummary prints the writes a dry run would have made.
+func printSummary(stdout io.Writer, s shared.Summary) {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		log.Errorw("could not print dry run summary", zap.Error(err))
+	}
+}
+
+// publish sends a message for a job to the cron topic. The Pub/Sub client
+// talks to the emulator at PUBSUB_EMULATOR_HOST if it is set.
+func publish(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	f := newJobFlags("publish", stderr)
+	topic := f.fs.String("topic", cron.Service, "Pub/Sub topic to publish to")
+	msg, err := f.parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	data, err := msg.Payload()
+	if err != nil {
+		log.Errorw("could not encode message", zap.Error(err))
+		return exitFailed
+	}
+
+	shutdown, err := tracing.Setup(ctx, cron.Service)
+	if err != nil {
+		log.Errorw("could not set up tracing", zap.Error(err))
+		return exitFailed
+	}
+	defer shutdown(ctx)
+
+	ctx, span := tracing.Start(ctx, "cron.publish", attribute.String("cron.job", msg.Job))
+	defer span.End()
+
+	id, err := publishMessage(ctx, *topic, data)
+	if err != nil {
+		tracing.Error(span, err)
+		log.Errorw("could not publish message", "job", msg.Job, "topic", *topic, zap.Error(err))
+		return exitFailed
+	}
+
+	where := cron.GCPProject
+	if host := os.Getenv("PUBSUB_EMULATOR_HOST"); host != "" {
+		where = "emulator at " + host
+	}
+	fmt.Fprintf(stdout, "published %s to %s (%s) as message %s\n", data, *topic, where, id)
+
+	return exitOK
+}
+
+func publishMessage(ctx context.Context, topic string, data []byte) (string, error) {
+	client, err := pubsub.NewClient(ctx, cron.GCPProject)
+	if err != nil {
+		return "", fmt.Errorf("create pubsub client: %w", err)
+	}
+	defer client.Close()
+
+	t := client.Topic(topic)
+	if os.Getenv("PUBSUB_EMULATOR_HOST") != "" {
+		// The emulator starts out empty.
+		ok, err := t.Exists(ctx)
+		if err != nil {
+			return "", fmt.Errorf("check topic %q: %w", topic, err)
+		}
+		if !ok {
+			if t, err = client.CreateTopic(ctx, topic); err != nil {
+				return "", fmt.Errorf("create topic %q: %w", topic, err)
+			}
+		}
+	}
+	defer t.Stop()
+
+	return t.Publish(ctx, &pubsub.Message{Data: data, Attributes: tracing.Inject(ctx)}).Get(ctx)
+}
+
+// listRuns prints recent runs from the run store without opening it for
+// writing, so it is safe while the server is running.
+func listRuns(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("runs", stderr)
+	job := fs.String("job", "", "only show runs of this job")
+	status := fs.String("status", "", "only show runs with this status, like failed")
+	limit := fs.Int("limit", 20, "most runs to show")
+	asJSON := fs.Bool("json", false, "print runs as JSON")
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if *limit < 1 {
+		fmt.Fprintln(stderr, "-limit must be a positive number")
+		return exitUsage
+	}
+
+	m, err := runs.ReadFile(filepath.Join(cron.DataDir(), "runs.jsonl"), filepath.Join(cron.DataDir(), "cli-runs.jsonl"))
+	if err != nil {
+		log.Errorw("could not read runs", zap.Error(err))
+		return exitFailed
+	}
+
+	list, err := m.List(ctx, runs.Filter{Job: *job, Status: runs.Status(*status), Limit: *limit})
+	if err != nil {
+		log.Errorw("could not list runs", zap.Error(err))
+		return exitFailed
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(list); err != nil {
+			log.Errorw("could not print runs", zap.Error(err))
+			return exitFailed
+		}
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STARTED\tJOB\tSTATUS\tTOOK\tATTEMPTS\tERROR")
+	for _, r := range list {
+		took := "-"
+		if !r.Finished.IsZero() {
+			took = r.Duration().Round(time.Millisecond).String()
+		}
+		status := string(r.Status)
+		if r.DryRun {
+			status += " (dry run)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", r.Started.Local().Format(time.DateTime), r.Job, status, took, r.Attempts, r.Error)
+	}
+	w.Flush()
+
+	return exitOK
+}
+
+// schedulerSync makes Cloud Scheduler match the schedules jobs declare.
+func schedulerSync(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("scheduler-sync", stderr)
+	dryRun := fs.Bool("dry-run", false, "print the changes without making them")
+	region := fs.String("region", "us-central1", "region the Cloud Scheduler jobs live in")
+	tz := fs.String("tz", "UTC", "timezone for schedules that don't set CRON_TZ or TZ")
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+
+	c, err := scheduler.NewCloud(ctx)
+	if err != nil {
+		log.Errorw("could not create scheduler client", zap.Error(err))
+		return exitFailed
+	}
+	defer c.Close()
+
+	s := &scheduler.Sync{
+		Log:      log,
+		Client:   c,
+		Project:  cron.GCPProject,
+		Region:   *region,
+		Topic:    cron.Service,
+		TimeZone: *tz,
+	}
+
+	plan, err := s.Plan(ctx, cron.DeclaredSchedules())
+	if err != nil {
+		log.Errorw("could not plan scheduler sync", zap.Error(err))
+		return exitFailed
+	}
+	fmt.Fprint(stdout, plan)
+
+	if *dryRun {
+		return exitOK
+	}
+
+	if err := s.Apply(ctx, plan); err != nil {
+		log.Errorw("could not sync scheduler", zap.Error(err))
+		return exitFailed
+	}
+
+	return exitOK
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	t.Setenv("CRON_DATA_DIR", t.TempDir())
+
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{nil, exitUsage, "", "Usage:"},
+		{[]string{"nope"}, exitUsage, "", `unknown command "nope"`},
+		{[]string{"help"}, exitOK, "Usage:", ""},
+		{[]string{"list"}, exitOK, "minute", ""},
+		{[]string{"run"}, exitUsage, "", "a job is required"},
+		{[]string{"run", "nope"}, exitUsage, "", `unknown job "nope"`},
+		{[]string{"run", "code", "-arg", "from=yesterday"}, exitUsage, "", `invalid argument "from"`},
+		{[]string{"run", "minute", "extra"}, exitUsage, "", "unexpected arguments"},
+		{[]string{"publish", "minute", "-arg", "nope"}, exitUsage, "", `"nope" is not key=value`},
+		{[]string{"run", "minute"}, exitOK, "status:   succeeded", ""},
+		{[]string{"run", "-dry-run", "minute"}, exitOK, `"total": 0`, ""},
+		{[]string{"run", "code"}, exitFailed, "missing secrets: GITHUB_TOKEN", ""},
+		{[]string{"runs", "-job", "minute"}, exitOK, "minute  succeeded (dry run)", ""},
+		{[]string{"runs", "-limit", "0"}, exitUsage, "", "-limit must be a positive number"},
+	}
+
+	// Running jobs must not change the transport every other client uses.
+	base := http.DefaultTransport
+	defer func() {
+		if http.DefaultTransport != base {
+			t.Error("http.DefaultTransport was replaced")
+		}
+	}()
+
+	for _, tc := range tests {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			t.Setenv("GITHUB_TOKEN", "")
+
+			var stdout, stderr bytes.Buffer
+			if got := dispatch(context.Background(), tc.args, &stdout, &stderr); got != tc.code {
+				t.Errorf("exit code = %d, want %d\nstdout: %s\nstderr: %s", got, tc.code, stdout.String(), stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tc.stdout) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout.String(), tc.stdout)
+			}
+			if !strings.Contains(stderr.String(), tc.stderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tc.stderr)
+			}
+		})
+	}
+}
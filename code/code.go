@@ -5,14 +5,18 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/dgraph-io/ristretto"
+	"github.com/google/go-github/v37/github"
 	"github.com/icco/code.natwelch.com/code"
+	"github.com/icco/cron/retry"
 	"github.com/icco/cron/shared"
 	"go.uber.org/zap"
+	"golang.org/x/oauth2"
 )
 
 // Config is a basic configuration struct.
@@ -26,6 +30,48 @@ type Config struct {
 
 // FetchAndSaveCommits gets all commits for the last 24 hours and saves to DB.
 func (cfg *Config) FetchAndSaveCommits(ctx context.Context) error {
+	from, to, err := Window(cfg.Now(), time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	return cfg.FetchAndSaveCommitsBetween(ctx, from, to)
+}
+
+// MaxWindow is the longest window of commits fetched in one run. Each hour is
+// a download from githubarchive.
+const MaxWindow = 31 * 24 * time.Hour
+
+// Window returns the hours to fetch commits for at now, given the first and
+// last days asked for, either of which may be zero. The last day is
+// inclusive. The most recent hour is left out since githubarchive won't have
+// it yet, and without a first day the window is 24 hours long. Windows longer
+// than MaxWindow are rejected.
+func Window(now, first, last time.Time) (from, to time.Time, err error) {
+	to = now.UTC().Add(-1 * time.Hour)
+	if !last.IsZero() {
+		if end := last.UTC().Add(24 * time.Hour); end.Before(to) {
+			to = end
+		}
+	}
+
+	from = first.UTC()
+	if first.IsZero() {
+		from = to.Add(-24 * time.Hour)
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, retry.Permanent(fmt.Errorf("from %s must be before to %s", from.Format(time.DateOnly), to.Format(time.DateOnly)))
+	}
+	if to.Sub(from) > MaxWindow {
+		return time.Time{}, time.Time{}, retry.Permanent(fmt.Errorf("from %s to %s is more than %d days, fetch it in smaller windows", from.Format(time.DateOnly), to.Format(time.DateOnly), MaxWindow/(24*time.Hour)))
+	}
+
+	return from, to, nil
+}
+
+// FetchAndSaveCommitsBetween gets all commits for each hour from from until to
+// and saves them to DB.
+func (cfg *Config) FetchAndSaveCommitsBetween(ctx context.Context, from, to time.Time) error {
 	if cfg.Cache == nil {
 		cache, err := ristretto.NewCache(&ristretto.Config{
 			NumCounters: 1e7,     // Num keys to track frequency of (10M).
@@ -38,11 +84,8 @@ func (cfg *Config) FetchAndSaveCommits(ctx context.Context) error {
 		cfg.Cache = cache
 	}
 
-	now := time.Now().UTC().Add(-1 * time.Hour)
-	yesterday := now.Add(-24 * time.Hour)
-
 	var tosave []*code.Commit
-	for i := yesterday; i.Before(now); i = i.Add(time.Hour) {
+	for i := from.UTC(); i.Before(to); i = i.Add(time.Hour) {
 		cfg.Log.Debugw("fetching one hour of commits", "time", i)
 		cmts, err := cfg.FetchCommits(ctx, i.Year(), i.Month(), i.Day(), i.Hour())
 		if err != nil {
@@ -64,8 +107,8 @@ func (cfg *Config) FetchAndSaveCommits(ctx context.Context) error {
 // FetchCommits gets all commits from githubarchive.org for a user at an hour.
 func (cfg *Config) FetchCommits(ctx context.Context, year int, month time.Month, day, hour int) ([]*code.Commit, error) {
 	t := time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
-	if time.Now().Before(t) {
-		return nil, fmt.Errorf("cannot fetch commits for the future. %v is after %v", t, time.Now())
+	if now := cfg.Now(); now.Before(t) {
+		return nil, retry.Permanent(fmt.Errorf("cannot fetch commits for the future. %v is after %v", t, now))
 	}
 	u := fmt.Sprintf("https://data.githubarchive.org/%s.json.gz", t.Format("2006-01-02-15"))
 
@@ -74,16 +117,13 @@ func (cfg *Config) FetchCommits(ctx context.Context, year int, month time.Month,
 	if err != nil {
 		return nil, fmt.Errorf("build request: %w", err)
 	}
-	req.Header.Add("User-Agent", "icco-cron/1.0")
-
-	client := http.DefaultClient
-	resp, err := client.Do(req)
+	resp, err := cfg.HTTPClient().Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("get archive %q: %w", u, err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("get archive %q != 200: got %s", u, resp.Status)
+		return nil, retry.FromStatus(resp.StatusCode, fmt.Errorf("get archive %q != 200: got %s", u, resp.Status))
 	}
 
 	rdr, err := gzip.NewReader(resp.Body)
@@ -133,13 +173,16 @@ func (cfg *Config) GetUserByEmail(ctx context.Context, email string) (string, er
 	if ok {
 		return user.(string), nil
 	}
-	client := code.GithubClient(ctx, cfg.GithubToken)
+	client := code.GithubClient(context.WithValue(ctx, oauth2.HTTPClient, cfg.HTTPClient()), cfg.GithubToken)
 
 	result, _, err := client.Search.Users(ctx, email, nil)
 	if err != nil {
-		if !code.RateLimited(err, cfg.Log) {
-			return "", fmt.Errorf("finding user: %w", err)
+		var rle *github.RateLimitError
+		if code.RateLimited(err, cfg.Log) && errors.As(err, &rle) {
+			return "", retry.RateLimited(fmt.Errorf("finding user: %w", err), rle.Rate.Reset.Time)
 		}
+
+		return "", fmt.Errorf("finding user: %w", err)
 	}
 
 	cfg.Log.Debugw("got users", "users", result, "query", email)
@@ -152,8 +195,16 @@ func (cfg *Config) GetUserByEmail(ctx context.Context, email string) (string, er
 	return user.(string), nil
 }
 
+// saveURL is where commits are saved.
+const saveURL = "https://code.natwelch.com/save"
+
 // Save saves a commit.
 func (cfg *Config) Save(ctx context.Context, commit *code.Commit) error {
+	if cfg.DryRun != nil {
+		cfg.DryRun.Record(shared.WriteHTTP, "POST "+saveURL, commit)
+		return nil
+	}
+
 	b, err := json.Marshal(commit)
 	if err != nil {
 		return fmt.Errorf("could not marshal commit: %w", err)
@@ -162,20 +213,19 @@ func (cfg *Config) Save(ctx context.Context, commit *code.Commit) error {
 	req, err := http.NewRequestWithContext(
 		ctx,
 		http.MethodPost,
-		"https://code.natwelch.com/save",
+		saveURL,
 		bytes.NewBuffer(b))
 	if err != nil {
 		return fmt.Errorf("could not build request: %w", err)
 	}
 
-	client := http.DefaultClient
-	resp, err := client.Do(req)
+	resp, err := cfg.HTTPClient().Do(req)
 	if err != nil {
 		return fmt.Errorf("could not save commit: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("save commit %+v: got %s", commit, resp.Status)
+		return retry.FromStatus(resp.StatusCode, fmt.Errorf("save commit %+v: got %s", commit, resp.Status))
 	}
 
 	return nil
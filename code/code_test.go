@@ -0,0 +1,253 @@
+package code
+
+import (
+	"compress/gzip"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/icco/code.natwelch.com/code"
+	"github.com/icco/cron/gqltest"
+	"github.com/icco/cron/replay"
+	"github.com/icco/cron/retry"
+	"github.com/icco/cron/shared"
+	"go.uber.org/zap"
+)
+
+func TestWindow(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v.UTC()
+	}
+	day := func(s string) time.Time {
+		v, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name        string
+		now         time.Time
+		first, last time.Time
+		from, to    time.Time
+		wantErr     bool
+	}{
+		{
+			name: "last day",
+			now:  utc("2024-01-10T02:00:00Z"),
+			from: utc("2024-01-09T01:00:00Z"),
+			to:   utc("2024-01-10T01:00:00Z"),
+		},
+		{
+			name: "midnight UTC",
+			now:  utc("2024-01-10T00:00:00Z"),
+			from: utc("2024-01-08T23:00:00Z"),
+			to:   utc("2024-01-09T23:00:00Z"),
+		},
+		{
+			name: "local clock across spring forward",
+			now:  time.Date(2024, 3, 10, 3, 30, 0, 0, ny),
+			from: utc("2024-03-09T06:30:00Z"),
+			to:   utc("2024-03-10T06:30:00Z"),
+		},
+		{
+			name: "local clock across fall back",
+			now:  time.Date(2024, 11, 3, 1, 30, 0, 0, ny).Add(time.Hour),
+			from: utc("2024-11-02T05:30:00Z"),
+			to:   utc("2024-11-03T05:30:00Z"),
+		},
+		{
+			name:  "first and last days",
+			now:   utc("2024-02-01T12:00:00Z"),
+			first: day("2024-01-01"),
+			last:  day("2024-01-07"),
+			from:  utc("2024-01-01T00:00:00Z"),
+			to:    utc("2024-01-08T00:00:00Z"),
+		},
+		{
+			name:  "last day is today",
+			now:   utc("2024-01-07T10:00:00Z"),
+			first: day("2024-01-06"),
+			last:  day("2024-01-07"),
+			from:  utc("2024-01-06T00:00:00Z"),
+			to:    utc("2024-01-07T09:00:00Z"),
+		},
+		{
+			name: "only last day",
+			now:  utc("2024-02-01T12:00:00Z"),
+			last: day("2024-01-07"),
+			from: utc("2024-01-07T00:00:00Z"),
+			to:   utc("2024-01-08T00:00:00Z"),
+		},
+		{
+			name:    "first day is today, just after midnight",
+			now:     utc("2024-01-01T00:30:00Z"),
+			first:   day("2024-01-01"),
+			wantErr: true,
+		},
+		{
+			name:  "longest window",
+			now:   utc("2024-03-01T12:00:00Z"),
+			first: day("2024-01-01"),
+			last:  day("2024-01-31"),
+			from:  utc("2024-01-01T00:00:00Z"),
+			to:    utc("2024-02-01T00:00:00Z"),
+		},
+		{
+			name:    "only first day, long ago",
+			now:     utc("2024-01-10T02:00:00Z"),
+			first:   day("2020-01-01"),
+			wantErr: true,
+		},
+		{
+			name:    "too long",
+			now:     utc("2024-03-01T12:00:00Z"),
+			first:   day("2024-01-01"),
+			last:    day("2024-02-01"),
+			wantErr: true,
+		},
+		{
+			name:    "first after last",
+			now:     utc("2024-02-01T12:00:00Z"),
+			first:   day("2024-01-08"),
+			last:    day("2024-01-07"),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			from, to, err := Window(tc.now, tc.first, tc.last)
+			if tc.wantErr {
+				if retry.ClassOf(err) != retry.ClassPermanent {
+					t.Fatalf("expected a permanent error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !from.Equal(tc.from) || !to.Equal(tc.to) {
+				t.Errorf("Window() = %s, %s, want %s, %s", from, to, tc.from, tc.to)
+			}
+			if from.Location() != time.UTC || to.Location() != time.UTC {
+				t.Errorf("expected UTC times, got %s and %s", from.Location(), to.Location())
+			}
+		})
+	}
+}
+
+func TestFetchCommitsFuture(t *testing.T) {
+	srv := gqltest.NewServer(t)
+	srv.Upstream("data.githubarchive.org", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gzip.NewWriter(w).Close()
+	}))
+
+	cache, err := ristretto.NewCache(&ristretto.Config{NumCounters: 10, MaxCost: 10, BufferItems: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := shared.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	cfg := &Config{
+		Config: shared.Config{Log: zap.NewNop().Sugar(), Clock: clock},
+		User:   "icco",
+		Cache:  cache,
+	}
+
+	tests := []struct {
+		name   string
+		year   int
+		month  time.Month
+		day    int
+		hour   int
+		future bool
+	}{
+		{"the hour it is", 2024, time.January, 1, 0, false},
+		{"the hour before midnight", 2023, time.December, 31, 23, false},
+		{"the next hour", 2024, time.January, 1, 1, true},
+		{"tomorrow", 2024, time.January, 2, 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := cfg.FetchCommits(context.Background(), tc.year, tc.month, tc.day, tc.hour)
+			if tc.future && retry.ClassOf(err) != retry.ClassPermanent {
+				t.Errorf("expected a permanent error for a future hour, got %v", err)
+			}
+			if !tc.future && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFetchCommits(t *testing.T) {
+	token := replay.Secret(t, "GITHUB_TOKEN", "token")
+
+	cache, err := ristretto.NewCache(&ristretto.Config{NumCounters: 10, MaxCost: 10, BufferItems: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{
+		Config: shared.Config{
+			Log:   zap.NewNop().Sugar(),
+			Clock: shared.NewFakeClock(time.Date(2024, 1, 2, 4, 0, 0, 0, time.UTC)),
+			HTTP:  &shared.HTTP{Base: replay.New(t, "testdata/githubarchive.replay.json", token)},
+		},
+		User:        "icco",
+		GithubToken: token,
+		Cache:       cache,
+	}
+
+	got, err := cfg.FetchCommits(context.Background(), 2024, time.January, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*code.Commit{
+		{Repo: "icco/cron", SHA: "4f2a9c1e8b7d6a5f4e3d2c1b0a9f8e7d6c5b4a39", User: "icco"},
+		{Repo: "icco/cron", SHA: "9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2f1e0d", User: "icco"},
+		{Repo: "icco/writing", SHA: "1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d", User: "octocat"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d commits, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != *want[i] {
+			t.Errorf("commit %d = %+v, want %+v", i, *got[i], *want[i])
+		}
+	}
+}
+
+func TestSaveDryRun(t *testing.T) {
+	// Nothing is served, so a commit that was really sent would fail.
+	gqltest.NewServer(t)
+
+	rec := &shared.Recorder{}
+	cfg := &Config{Config: shared.Config{Log: zap.NewNop().Sugar(), DryRun: rec}}
+
+	c := &code.Commit{Repo: "icco/cron", SHA: "4f2a9c1e8b7d6a5f4e3d2c1b0a9f8e7d6c5b4a39", User: "icco"}
+	if err := cfg.Save(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+
+	w := rec.Writes()
+	if len(w) != 1 || w[0].Kind != shared.WriteHTTP || w[0].Target != "POST https://code.natwelch.com/save" || w[0].Payload != c {
+		t.Errorf("writes = %+v", w)
+	}
+}
@@ -0,0 +1,45 @@
+package code
+
+import (
+	"context"
+
+	"github.com/icco/cron/jobs"
+)
+
+func init() {
+	jobs.Register(jobs.New(
+		"code",
+		"Saves commits from githubarchive to code.natwelch.com. Defaults to the last day.",
+		func(ctx context.Context, cfg *jobs.Config) error {
+			c := &Config{
+				Config:      cfg.Config,
+				User:        "icco",
+				GithubToken: cfg.Secret("GITHUB_TOKEN"),
+				Cache:       cfg.Cache,
+			}
+
+			first, _, err := cfg.Args.Date("from")
+			if err != nil {
+				return err
+			}
+			last, _, err := cfg.Args.Date("to")
+			if err != nil {
+				return err
+			}
+
+			from, to, err := Window(c.Now(), first, last)
+			if err != nil {
+				return err
+			}
+
+			return c.FetchAndSaveCommitsBetween(ctx, from, to)
+		},
+		jobs.WithSecrets("GITHUB_TOKEN"),
+		jobs.WithArgs(
+			jobs.Arg{Name: "from", Description: "First day to fetch, like 2024-01-01.", Validate: jobs.ValidateDate},
+			jobs.Arg{Name: "to", Description: "Last day to fetch, like 2024-01-07.", Validate: jobs.ValidateDate},
+		),
+		jobs.WithConcurrency(jobs.Forbid, 0),
+		jobs.WithSchedule("0 2 * * *"),
+	))
+}
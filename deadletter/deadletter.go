@@ -0,0 +1,132 @@
+// Package deadletter keeps messages whose jobs failed for good, so they can be
+// looked at and replayed or discarded by hand.
+package deadletter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// ErrNotFound is returned when an entry does not exist.
+var ErrNotFound = errors.New("dead letter not found")
+
+// Entry is a message that was dead lettered.
+type Entry struct {
+	ID        string            `json:"id"`
+	MessageID string            `json:"message_id,omitempty"`
+	Job       string            `json:"job"`
+	Args      map[string]string `json:"args,omitempty"`
+	RunID     string            `json:"run_id,omitempty"`
+	Attempts  int               `json:"attempts"`
+	LastError string            `json:"last_error"`
+	Added     time.Time         `json:"added"`
+}
+
+// Store holds dead lettered messages.
+type Store interface {
+	// Add stores an entry.
+	Add(ctx context.Context, e *Entry) error
+
+	// List returns every entry, oldest first.
+	List(ctx context.Context) ([]*Entry, error)
+
+	// Get returns an entry by ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (*Entry, error)
+
+	// Remove deletes an entry, returning ErrNotFound if it doesn't exist.
+	Remove(ctx context.Context, id string) error
+}
+
+// Notifier is told about every new entry.
+type Notifier func(ctx context.Context, e *Entry) error
+
+// Webhook returns a Notifier that POSTs each entry as JSON to url with
+// client.
+func Webhook(client *http.Client, url string) Notifier {
+	return func(ctx context.Context, e *Entry) error {
+		b, err := json.Marshal(map[string]any{"dead_letter": e})
+		if err != nil {
+			return fmt.Errorf("marshal entry: %w", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("build request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("post %q: %w", url, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("post %q: got %s", url, resp.Status)
+		}
+
+		return nil
+	}
+}
+
+type notifying struct {
+	Store
+
+	notify Notifier
+	onErr  func(error)
+}
+
+// WithNotifier wraps s so n is called after each Add. Notification failures
+// are passed to onErr, if set, and do not fail the Add.
+func WithNotifier(s Store, n Notifier, onErr func(error)) Store {
+	return &notifying{Store: s, notify: n, onErr: onErr}
+}
+
+func (n *notifying) Add(ctx context.Context, e *Entry) error {
+	if err := n.Store.Add(ctx, e); err != nil {
+		return err
+	}
+
+	if err := n.notify(ctx, e); err != nil && n.onErr != nil {
+		n.onErr(err)
+	}
+
+	return nil
+}
+
+type topic struct {
+	Store
+
+	topic *pubsub.Topic
+}
+
+// WithTopic wraps s so each entry is also published, as JSON, to a Pub/Sub
+// dead letter topic. Entries are still kept in s so they can be listed and
+// replayed.
+func WithTopic(s Store, t *pubsub.Topic) Store {
+	return &topic{Store: s, topic: t}
+}
+
+func (t *topic) Add(ctx context.Context, e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal entry: %w", err)
+	}
+
+	res := t.topic.Publish(ctx, &pubsub.Message{
+		Data:       b,
+		Attributes: map[string]string{"job": e.Job},
+	})
+	if _, err := res.Get(ctx); err != nil {
+		return fmt.Errorf("publish to %s: %w", t.topic, err)
+	}
+
+	return t.Store.Add(ctx, e)
+}
@@ -0,0 +1,138 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/icco/cron/journal"
+)
+
+// record is a line in the journal. Removals are written as tombstones.
+type record struct {
+	Entry   *Entry `json:"entry,omitempty"`
+	Removed string `json:"removed,omitempty"`
+}
+
+// File is a Store backed by a journal on local disk.
+type File struct {
+	journal *journal.Journal
+
+	mu      sync.RWMutex
+	entries map[string]*Entry
+}
+
+// OpenFile opens or creates a file store at path.
+func OpenFile(path string) (*File, error) {
+	j, err := journal.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{journal: j, entries: map[string]*Entry{}}
+	if err := j.Replay(func(b json.RawMessage) error {
+		var r record
+		if err := json.Unmarshal(b, &r); err != nil {
+			return fmt.Errorf("decode dead letter: %w", err)
+		}
+
+		if r.Entry != nil {
+			f.entries[r.Entry.ID] = r.Entry
+		}
+		if r.Removed != "" {
+			delete(f.entries, r.Removed)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	all := f.sorted()
+	records := make([]any, len(all))
+	for i, e := range all {
+		records[i] = record{Entry: e}
+	}
+	if err := j.Rewrite(records); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Add implements Store.
+func (f *File) Add(ctx context.Context, e *Entry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.journal.Append(record{Entry: e}); err != nil {
+		return fmt.Errorf("append dead letter: %w", err)
+	}
+
+	cp := *e
+	f.entries[e.ID] = &cp
+	return nil
+}
+
+// List implements Store.
+func (f *File) List(ctx context.Context) ([]*Entry, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	all := f.sorted()
+	out := make([]*Entry, len(all))
+	for i, e := range all {
+		cp := *e
+		out[i] = &cp
+	}
+
+	return out, nil
+}
+
+// Get implements Store.
+func (f *File) Get(ctx context.Context, id string) (*Entry, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	e, ok := f.entries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	cp := *e
+	return &cp, nil
+}
+
+// Remove implements Store.
+func (f *File) Remove(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.entries[id]; !ok {
+		return ErrNotFound
+	}
+
+	if err := f.journal.Append(record{Removed: id}); err != nil {
+		return fmt.Errorf("append removal: %w", err)
+	}
+
+	delete(f.entries, id)
+	return nil
+}
+
+// Close closes the underlying journal.
+func (f *File) Close() error {
+	return f.journal.Close()
+}
+
+// sorted returns every entry, oldest first. Callers must hold mu.
+func (f *File) sorted() []*Entry {
+	all := make([]*Entry, 0, len(f.entries))
+	for _, e := range f.entries {
+		all = append(all, e)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Added.Before(all[j].Added) })
+
+	return all
+}
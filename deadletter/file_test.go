@@ -0,0 +1,60 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "deadletters.jsonl")
+
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c"} {
+		e := &Entry{ID: id, Job: "code", Attempts: 3, LastError: "503", Added: added.Add(time.Duration(i) * time.Minute)}
+		if err := f.Add(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Remove(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Remove(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected removing twice to be ErrNotFound, got %+v", err)
+	}
+	f.Close()
+
+	f, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	list, err := f.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "a" || list[1].ID != "c" {
+		t.Errorf("expected a and c to survive a reopen, got %+v", list)
+	}
+
+	var notified *Entry
+	s := WithNotifier(f, func(ctx context.Context, e *Entry) error {
+		notified = e
+		return errors.New("hook is down")
+	}, nil)
+	if err := s.Add(ctx, &Entry{ID: "d", Job: "spider"}); err != nil {
+		t.Errorf("expected a failed notification not to fail Add, got %+v", err)
+	}
+	if notified == nil || notified.ID != "d" {
+		t.Errorf("expected d to be notified, got %+v", notified)
+	}
+}
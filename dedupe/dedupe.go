@@ -0,0 +1,79 @@
+// Package dedupe remembers which messages have been handled, so that
+// redelivered Pub/Sub messages don't run a job twice.
+package dedupe
+
+import (
+	"sync"
+	"time"
+
+	"github.com/icco/cron/metrics"
+)
+
+// Window remembers message IDs for a period of time. The zero value is not
+// usable, use NewWindow.
+type Window struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewWindow creates a window that remembers IDs for ttl.
+func NewWindow(ttl time.Duration) *Window {
+	return &Window{
+		ttl:  ttl,
+		now:  time.Now,
+		seen: map[string]time.Time{},
+	}
+}
+
+// Claim marks id as handled. It returns false if id was already claimed
+// within the window, meaning the message is a duplicate.
+func (w *Window) Claim(id string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	w.sweep(now)
+
+	if expires, ok := w.seen[id]; ok && now.Before(expires) {
+		metrics.Duplicates.Inc()
+		return false
+	}
+
+	w.seen[id] = now.Add(w.ttl)
+	return true
+}
+
+// Release forgets id, so that a redelivery of it is handled again. Call it
+// when handling a message fails.
+func (w *Window) Release(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.seen, id)
+}
+
+// Len is the number of IDs being remembered.
+func (w *Window) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.seen)
+}
+
+// sweep drops expired IDs at most once per ttl. Callers must hold mu.
+func (w *Window) sweep(now time.Time) {
+	if now.Sub(w.lastSweep) < w.ttl {
+		return
+	}
+
+	for id, expires := range w.seen {
+		if !now.Before(expires) {
+			delete(w.seen, id)
+		}
+	}
+	w.lastSweep = now
+}
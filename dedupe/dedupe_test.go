@@ -0,0 +1,32 @@
+package dedupe
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewWindow(time.Hour)
+	w.now = func() time.Time { return now }
+
+	if !w.Claim("a") {
+		t.Fatal("expected first claim of a to succeed")
+	}
+	if w.Claim("a") {
+		t.Error("expected second claim of a to be a duplicate")
+	}
+
+	w.Release("a")
+	if !w.Claim("a") {
+		t.Error("expected claim after release to succeed")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if !w.Claim("a") {
+		t.Error("expected claim after the window to succeed")
+	}
+	if !w.Claim("b") || w.Len() != 2 {
+		t.Errorf("expected a and b to be remembered, have %d", w.Len())
+	}
+}
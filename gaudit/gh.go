@@ -2,9 +2,11 @@ package gaudit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/google/go-github/v48/github"
+	"github.com/icco/cron/retry"
 	"github.com/icco/cron/shared"
 	"golang.org/x/oauth2"
 )
@@ -17,10 +19,14 @@ type Config struct {
 }
 
 func (c *Config) CheckRepos(ctx context.Context) error {
-	client := GithubClient(ctx, c.GithubToken)
+	client := GithubClient(context.WithValue(ctx, oauth2.HTTPClient, c.HTTPClient()), c.GithubToken)
 	opt := &github.RepositoryListOptions{Type: "owner", Sort: "updated", Direction: "desc"}
 
 	repos, _, err := client.Repositories.List(ctx, c.User, opt)
+	var rle *github.RateLimitError
+	if errors.As(err, &rle) {
+		return retry.RateLimited(err, rle.Rate.Reset.Time)
+	}
 	if err != nil {
 		return err
 	}
@@ -32,6 +38,8 @@ func (c *Config) CheckRepos(ctx context.Context) error {
 	return nil
 }
 
+// GithubClient creates a client that authenticates with token. It makes
+// requests with the *http.Client in ctx under oauth2.HTTPClient, if any.
 func GithubClient(ctx context.Context, token string) *github.Client {
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
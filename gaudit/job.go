@@ -0,0 +1,26 @@
+package gaudit
+
+import (
+	"context"
+
+	"github.com/icco/cron/jobs"
+)
+
+func init() {
+	jobs.Register(jobs.New(
+		"github-audit",
+		"Logs every repo we own on GitHub.",
+		func(ctx context.Context, cfg *jobs.Config) error {
+			c := &Config{
+				Config:      cfg.Config,
+				User:        "icco",
+				GithubToken: cfg.Secret("GITHUB_TOKEN"),
+			}
+
+			return c.CheckRepos(ctx)
+		},
+		jobs.WithSecrets("GITHUB_TOKEN"),
+		jobs.WithConcurrency(jobs.Forbid, 0),
+		jobs.WithSchedule("0 9 * * 1"),
+	))
+}
@@ -2,13 +2,16 @@ package goodreads
 
 import (
 	"context"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 
-	"github.com/KyleBanks/goodreads"
 	"github.com/KyleBanks/goodreads/responses"
+	"github.com/icco/cron/retry"
 	"github.com/icco/cron/shared"
 	gql "github.com/icco/graphql"
-	"github.com/machinebox/graphql"
 	"go.uber.org/zap"
 )
 
@@ -16,14 +19,45 @@ import (
 type Goodreads struct {
 	shared.Config
 
-	Token        string
-	GraphQLToken string
+	Token   string
+	GraphQL *shared.GraphQL
 }
 
-// GetBooks gets the 100 most recent reviews for Nat.
+// GetBooks gets the 200 most recent reviews for Nat. The goodreads client
+// can't be given an HTTP client, so this makes its request itself.
 func (g *Goodreads) GetBooks(ctx context.Context) ([]responses.Review, error) {
-	c := goodreads.NewClient(g.Token)
-	return c.ReviewList("18143346", "read", "date_read", "", "d", 1, 200)
+	v := url.Values{}
+	v.Set("key", g.Token)
+	v.Set("v", "2")
+	v.Set("shelf", "read")
+	v.Set("sort", "date_read")
+	v.Set("order", "d")
+	v.Set("page", "1")
+	v.Set("per_page", "200")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.goodreads.com/review/list/18143346.xml?"+v.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+
+	resp, err := g.HTTPClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("list reviews: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, retry.FromStatus(resp.StatusCode, fmt.Errorf("list reviews: got %s", resp.Status))
+	}
+
+	var r struct {
+		Reviews []responses.Review `xml:"reviews>review"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("decode reviews: %w", err)
+	}
+
+	return r.Reviews, nil
 }
 
 // UpsertBooks gets books and uploads them.
@@ -33,11 +67,23 @@ func (g *Goodreads) UpsertBooks(ctx context.Context) error {
 		return fmt.Errorf("get books: %w", err)
 	}
 
-	for _, r := range reviews {
-		err := g.UploadBook(ctx, r.Book)
-		if err != nil {
-			return fmt.Errorf("upload book: %w", err)
+	upserts := make([]shared.Upsert, len(reviews))
+	for i, r := range reviews {
+		upserts[i] = shared.BookUpsert(editBook(r.Book))
+	}
+
+	err = g.GraphQL.Batch(ctx, upserts)
+	var be *shared.BatchError
+	if errors.As(err, &be) {
+		for _, i := range be.Indexes() {
+			g.Log.Errorw("could not upload book", zap.Error(be.Failed[i]), "id", reviews[i].Book.ID, "title", reviews[i].Book.Title)
 		}
+		// The rest were saved, and sending these again won't get them
+		// accepted, so they don't fail the run.
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("upload books: %w", err)
 	}
 
 	g.Log.Infow("uploaded books", "reviews", len(reviews))
@@ -47,29 +93,17 @@ func (g *Goodreads) UpsertBooks(ctx context.Context) error {
 
 // UploadBook uploads a single book.
 func (g *Goodreads) UploadBook(ctx context.Context, b responses.AuthorBook) error {
-	book := gql.EditBook{
-		ID:    &b.ID,
-		Title: &b.Title,
-	}
-
-	gqlClient := graphql.NewClient("https://graphql.natwelch.com/graphql")
-	mut := `
-  mutation ($b: EditBook!) {
-      upsertBook(input: $b) {
-        id
-      }
-    }
-  `
-
-	req := graphql.NewRequest(mut)
-	req.Var("b", book)
-	req.Header.Add("X-API-AUTH", g.GraphQLToken)
-	req.Header.Add("User-Agent", "icco-cron/1.0")
-	err := gqlClient.Run(ctx, req, nil)
-	if err != nil {
+	if err := g.GraphQL.UpsertBook(ctx, editBook(b)); err != nil {
 		g.Log.Errorw("error talking to graphql", zap.Error(err))
 		return err
 	}
 
 	return nil
 }
+
+func editBook(b responses.AuthorBook) gql.EditBook {
+	return gql.EditBook{
+		ID:    &b.ID,
+		Title: &b.Title,
+	}
+}
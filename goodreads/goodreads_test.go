@@ -0,0 +1,61 @@
+package goodreads
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/icco/cron/gqltest"
+	"github.com/icco/cron/replay"
+	"github.com/icco/cron/shared"
+	"go.uber.org/zap"
+)
+
+func TestUpsertBooks(t *testing.T) {
+	srv := gqltest.NewServer(t)
+	srv.Upstream("www.goodreads.com", gqltest.File(t, "testdata/reviews.xml"))
+
+	if err := srv.Run(t, "goodreads", map[string]string{"GOODREADS_TOKEN": "token"}); err != nil {
+		t.Fatal(err)
+	}
+
+	gqltest.Golden(t, "testdata/goodreads.golden.json", srv.Mutations())
+}
+
+func TestUpsertBooksRejected(t *testing.T) {
+	srv := gqltest.NewServer(t)
+	srv.Upstream("www.goodreads.com", gqltest.File(t, "testdata/reviews.xml"))
+	srv.Reject("upsertBook", "The Martian")
+
+	if err := srv.Run(t, "goodreads", map[string]string{"GOODREADS_TOKEN": "token"}); err != nil {
+		t.Fatalf("expected a rejected book not to fail the run, got %v", err)
+	}
+	if got := srv.Mutations(); len(got) != 1 {
+		t.Errorf("expected the other book to be saved, got %+v", got)
+	}
+}
+
+func TestGetBooks(t *testing.T) {
+	token := replay.Secret(t, "GOODREADS_TOKEN", "token")
+	g := &Goodreads{
+		Config: shared.Config{
+			Log:  zap.NewNop().Sugar(),
+			HTTP: &shared.HTTP{Base: replay.New(t, "testdata/goodreads.replay.json", token)},
+		},
+		Token: token,
+	}
+
+	reviews, err := g.GetBooks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var titles []string
+	for _, r := range reviews {
+		titles = append(titles, r.Book.Title)
+	}
+	want := []string{"The Martian", "The Way of Kings (The Stormlight Archive, #1)"}
+	if !slices.Equal(titles, want) {
+		t.Errorf("titles = %q, want %q", titles, want)
+	}
+}
@@ -0,0 +1,27 @@
+package goodreads
+
+import (
+	"context"
+
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/shared"
+)
+
+func init() {
+	jobs.Register(jobs.New(
+		"goodreads",
+		"Uploads recently read books to graphql.",
+		func(ctx context.Context, cfg *jobs.Config) error {
+			g := &Goodreads{
+				Config:  cfg.Config,
+				Token:   cfg.Secret("GOODREADS_TOKEN"),
+				GraphQL: shared.NewGraphQL(cfg.Config, cfg.Secret("GQL_TOKEN")),
+			}
+
+			return g.UpsertBooks(ctx)
+		},
+		jobs.WithSecrets("GQL_TOKEN", "GOODREADS_TOKEN"),
+		jobs.WithConcurrency(jobs.Forbid, 0),
+		jobs.WithSchedule("0 * * * *"),
+	))
+}
@@ -0,0 +1,271 @@
+// Package gqltest is an in-process fake of our GraphQL API for testing jobs.
+// It records the mutations jobs send, answers queries with canned data, and
+// serves the other APIs jobs call from fixtures, so tests never touch the
+// network.
+package gqltest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/shared"
+	"go.uber.org/zap"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the mutations jobs send")
+
+// Mutation is one mutation a job sent, with the input it was given.
+type Mutation struct {
+	Field string          `json:"field"`
+	Input json.RawMessage `json:"input"`
+}
+
+// Server is a fake of the GraphQL API.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	mutations []Mutation
+	responses map[string]any
+	upstream  map[string]http.Handler
+	rejects   []rejection
+}
+
+// rejection is a mutation the fake API refuses.
+type rejection struct {
+	field, match string
+}
+
+// NewServer starts a fake API and, until the test ends, points GQL_ENDPOINT
+// at it and routes requests made with http.DefaultTransport to it or to
+// handlers added with Upstream. Requests to any other host fail.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{
+		responses: map[string]any{},
+		upstream:  map[string]http.Handler{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveGraphQL))
+	t.Cleanup(s.Close)
+
+	t.Setenv("GQL_ENDPOINT", s.URL+"/graphql")
+
+	base := http.DefaultTransport
+	http.DefaultTransport = &transport{server: s, base: base}
+	t.Cleanup(func() { http.DefaultTransport = base })
+
+	return s
+}
+
+// Respond makes queries that ask for field get data back as its value.
+func (s *Server) Respond(field string, data any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses[field] = data
+}
+
+// Upstream serves requests to host with h.
+func (s *Server) Upstream(host string, h http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.upstream[host] = h
+}
+
+// Reject makes mutations of field whose input contains match fail with an
+// error for just that mutation, as the API does for input it won't accept.
+// Rejected mutations are not recorded.
+func (s *Server) Reject(field, match string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rejects = append(s.rejects, rejection{field: field, match: match})
+}
+
+func (s *Server) rejected(field string, input json.RawMessage) bool {
+	for _, r := range s.rejects {
+		if r.field == field && strings.Contains(string(input), r.match) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mutations returns the mutations received so far, in the order they came.
+func (s *Server) Mutations() []Mutation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Mutation(nil), s.mutations...)
+}
+
+// Run runs a registered job with secrets, logging to t.
+func (s *Server) Run(t testing.TB, job string, secrets map[string]string) error {
+	t.Helper()
+
+	j, ok := jobs.Get(job)
+	if !ok {
+		t.Fatalf("no job named %q", job)
+	}
+
+	return j.Run(context.Background(), &jobs.Config{
+		Config:  shared.Config{Log: zap.NewNop().Sugar()},
+		Secrets: secrets,
+	})
+}
+
+// call matches a mutation field, like "u0: upsertStat(input: $u0)" or
+// "upsertStat(input: $s)".
+var call = regexp.MustCompile(`(?:(\w+)\s*:\s*)?(\w+)\s*\(\s*input\s*:\s*\$(\w+)\s*\)`)
+
+func (s *Server) serveGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query     string                     `json:"query"`
+		Variables map[string]json.RawMessage `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := map[string]any{}
+	var errs []map[string]any
+	if strings.HasPrefix(strings.TrimSpace(req.Query), "mutation") {
+		for _, m := range call.FindAllStringSubmatch(req.Query, -1) {
+			alias, field, v := m[1], m[2], m[3]
+			if alias == "" {
+				alias = field
+			}
+
+			if s.rejected(field, req.Variables[v]) {
+				errs = append(errs, map[string]any{"message": "gqltest: rejected", "path": []string{alias}})
+				data[alias] = nil
+				continue
+			}
+			s.mutations = append(s.mutations, Mutation{Field: field, Input: req.Variables[v]})
+			data[alias] = map[string]any{}
+		}
+	} else {
+		for field, resp := range s.responses {
+			if regexp.MustCompile(`\b` + regexp.QuoteMeta(field) + `\b`).MatchString(req.Query) {
+				data[field] = resp
+			}
+		}
+		if len(data) == 0 {
+			writeJSON(w, map[string]any{"errors": []map[string]string{{"message": "gqltest: no canned response for query"}}})
+			return
+		}
+	}
+
+	if len(errs) > 0 {
+		writeJSON(w, map[string]any{"data": data, "errors": errs})
+		return
+	}
+	writeJSON(w, map[string]any{"data": data})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// transport sends requests for the fake API to it and requests for upstream
+// hosts to their handlers.
+type transport struct {
+	server *Server
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == t.server.Listener.Addr().String() {
+		return t.base.RoundTrip(req)
+	}
+
+	host, _, err := net.SplitHostPort(req.URL.Host)
+	if err != nil {
+		host = req.URL.Host
+	}
+
+	t.server.mu.Lock()
+	h, ok := t.server.upstream[host]
+	t.server.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("gqltest: no upstream for %s", req.URL)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	resp := rec.Result()
+	resp.Request = req
+
+	return resp, nil
+}
+
+// File serves the contents of path.
+func File(t testing.TB, path string) http.Handler {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch filepath.Ext(path) {
+		case ".json":
+			w.Header().Set("Content-Type", "application/json")
+		case ".xml":
+			w.Header().Set("Content-Type", "application/xml")
+		}
+		w.Write(b)
+	})
+}
+
+// Golden compares got to the mutations in the golden file at path, or
+// rewrites the file when the tests are run with -update.
+func Golden(t testing.TB, path string, got []Mutation) {
+	t.Helper()
+
+	b, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = append(b, '\n')
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("mutations differ from %s (run with -update to accept them)\ngot:\n%s\nwant:\n%s", path, b, want)
+	}
+}
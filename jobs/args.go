@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DateFormat is the format of date arguments.
+const DateFormat = "2006-01-02"
+
+// Args are the arguments a job was called with.
+type Args map[string]string
+
+// Date parses the argument key as a date in UTC. The bool is false if the
+// argument was not given.
+func (a Args) Date(key string) (time.Time, bool, error) {
+	s, ok := a[key]
+	if !ok || s == "" {
+		return time.Time{}, false, nil
+	}
+
+	t, err := time.ParseInLocation(DateFormat, s, time.UTC)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("parse %q: %w", key, err)
+	}
+
+	return t, true, nil
+}
+
+// Arg describes an argument a job accepts.
+type Arg struct {
+	Name        string
+	Description string
+
+	// Validate checks a value. Nil means any value is accepted.
+	Validate func(string) error
+}
+
+// ValidateDate checks that a value is a date like 2024-01-07.
+func ValidateDate(s string) error {
+	if _, err := time.Parse(DateFormat, s); err != nil {
+		return fmt.Errorf("%q is not a date like %s", s, DateFormat)
+	}
+
+	return nil
+}
+
+// ValidateURL checks that a value is an absolute http or https URL.
+func ValidateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a url: %w", s, err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute http(s) url", s)
+	}
+
+	return nil
+}
+
+// ValidateArgs checks args against what j accepts. Unknown arguments and
+// invalid values are rejected.
+func ValidateArgs(j Job, args Args) error {
+	accepted := map[string]Arg{}
+	for _, a := range j.Args() {
+		accepted[a.Name] = a
+	}
+
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		a, ok := accepted[k]
+		if !ok {
+			if len(accepted) == 0 {
+				return fmt.Errorf("job %q takes no arguments, got %q", j.Name(), k)
+			}
+
+			names := make([]string, 0, len(accepted))
+			for n := range accepted {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+
+			return fmt.Errorf("unknown argument %q for job %q, accepted: %s", k, j.Name(), strings.Join(names, ", "))
+		}
+
+		if a.Validate != nil {
+			if err := a.Validate(args[k]); err != nil {
+				return fmt.Errorf("invalid argument %q for job %q: %w", k, j.Name(), err)
+			}
+		}
+	}
+
+	return nil
+}
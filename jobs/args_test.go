@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+)
+
+func TestValidateArgs(t *testing.T) {
+	noop := func(context.Context, *Config) error { return nil }
+	j := New("test", "", noop, WithArgs(
+		Arg{Name: "from", Validate: ValidateDate},
+		Arg{Name: "url", Validate: ValidateURL},
+	))
+	bare := New("bare", "", noop)
+
+	tests := []struct {
+		job     Job
+		args    Args
+		wantErr bool
+	}{
+		{j, Args{}, false},
+		{j, Args{"from": "2024-01-01", "url": "https://food.natwelch.com/"}, false},
+		{j, Args{"from": "01/01/2024"}, true},
+		{j, Args{"url": "food.natwelch.com"}, true},
+		{j, Args{"site": "gotak"}, true},
+		{bare, Args{}, false},
+		{bare, Args{"from": "2024-01-01"}, true},
+	}
+
+	for _, tc := range tests {
+		err := ValidateArgs(tc.job, tc.args)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ValidateArgs(%q, %+v): expected error %v, got %+v", tc.job.Name(), tc.args, tc.wantErr, err)
+		}
+	}
+}
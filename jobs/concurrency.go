@@ -0,0 +1,66 @@
+package jobs
+
+import "fmt"
+
+// Policy decides what happens when a job is triggered while it is already
+// running.
+type Policy int
+
+const (
+	// Allow lets up to Limit runs happen at once, and skips any more. A Limit
+	// of zero means there is no limit.
+	Allow Policy = iota
+
+	// Forbid skips a run if the job is already running.
+	Forbid
+
+	// Queue waits for running instances to finish, letting up to Limit (at
+	// least one) run at once.
+	Queue
+)
+
+func (p Policy) String() string {
+	switch p {
+	case Allow:
+		return "allow"
+	case Forbid:
+		return "forbid"
+	case Queue:
+		return "queue"
+	default:
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p Policy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// Concurrency is how many runs of a job may overlap.
+type Concurrency struct {
+	Policy Policy `json:"policy"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// Slots is how many runs may happen at once. Zero means unlimited.
+func (c Concurrency) Slots() int {
+	switch c.Policy {
+	case Forbid:
+		return 1
+	case Queue:
+		if c.Limit < 1 {
+			return 1
+		}
+	}
+
+	return c.Limit
+}
+
+// WithConcurrency sets the concurrency policy of a job. Jobs without one
+// allow unlimited overlapping runs.
+func WithConcurrency(p Policy, limit int) Option {
+	return func(j *funcJob) {
+		j.concurrency = Concurrency{Policy: p, Limit: limit}
+	}
+}
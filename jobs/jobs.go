@@ -0,0 +1,218 @@
+// Package jobs is the registry of work cron knows how to do. Each package
+// that does work registers its jobs here, and the dispatcher looks them up by
+// name.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/icco/cron/retry"
+	"github.com/icco/cron/shared"
+)
+
+// Config is what a job is given when it runs.
+type Config struct {
+	shared.Config
+
+	// Project is the GCP project jobs run in.
+	Project string
+
+	Cache   *ristretto.Cache
+	Secrets map[string]string
+	Args    Args
+}
+
+// Secret returns the value of a secret the job declared it needs.
+func (c *Config) Secret(key string) string {
+	return c.Secrets[key]
+}
+
+// RunFunc does the work of a job.
+type RunFunc func(ctx context.Context, cfg *Config) error
+
+// Job is a unit of work that can be triggered by name.
+type Job interface {
+	// Name is the value of "job" in a message that triggers this job.
+	Name() string
+
+	// Description is a human readable summary of what the job does.
+	Description() string
+
+	// Secrets lists the secrets the job needs to run.
+	Secrets() []string
+
+	// Args lists the arguments the job accepts.
+	Args() []Arg
+
+	// Concurrency is how overlapping runs of the job are handled.
+	Concurrency() Concurrency
+
+	// Retry is how failed runs of the job are retried.
+	Retry() retry.Policy
+
+	// Schedule is a cron expression for when the job should run, or empty if
+	// it only runs when asked.
+	Schedule() string
+
+	// Run does the work.
+	Run(ctx context.Context, cfg *Config) error
+}
+
+// Option configures a job created with New.
+type Option func(*funcJob)
+
+// WithSecrets declares the secrets a job needs.
+func WithSecrets(keys ...string) Option {
+	return func(j *funcJob) {
+		j.secrets = append(j.secrets, keys...)
+	}
+}
+
+// WithRetry sets how failed runs of a job are retried. Jobs without one use
+// retry.Default.
+func WithRetry(p retry.Policy) Option {
+	return func(j *funcJob) {
+		j.retry = p
+	}
+}
+
+// WithSchedule sets when a job runs, as a standard five field cron
+// expression. It may start with CRON_TZ=Area/City to set a timezone.
+func WithSchedule(spec string) Option {
+	return func(j *funcJob) {
+		j.schedule = spec
+	}
+}
+
+// WithArgs declares the arguments a job accepts.
+func WithArgs(args ...Arg) Option {
+	return func(j *funcJob) {
+		j.args = append(j.args, args...)
+	}
+}
+
+type funcJob struct {
+	name        string
+	description string
+	secrets     []string
+	args        []Arg
+	concurrency Concurrency
+	retry       retry.Policy
+	schedule    string
+	run         RunFunc
+}
+
+// New creates a Job from a function.
+func New(name, description string, run RunFunc, opts ...Option) Job {
+	j := &funcJob{
+		name:        name,
+		description: description,
+		retry:       retry.Default,
+		run:         run,
+	}
+	for _, o := range opts {
+		o(j)
+	}
+
+	return j
+}
+
+func (j *funcJob) Name() string        { return j.name }
+func (j *funcJob) Description() string { return j.description }
+func (j *funcJob) Secrets() []string   { return j.secrets }
+func (j *funcJob) Args() []Arg         { return j.args }
+
+func (j *funcJob) Concurrency() Concurrency { return j.concurrency }
+func (j *funcJob) Retry() retry.Policy      { return j.retry }
+func (j *funcJob) Schedule() string         { return j.schedule }
+
+func (j *funcJob) Run(ctx context.Context, cfg *Config) error {
+	return j.run(ctx, cfg)
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Job{}
+)
+
+// Register makes a job available by name. It panics if a job with the same
+// name is already registered, as that is always a programming error.
+func Register(j Job) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if j == nil {
+		panic("jobs: Register job is nil")
+	}
+
+	if _, dup := registry[j.Name()]; dup {
+		panic(fmt.Sprintf("jobs: Register called twice for %q", j.Name()))
+	}
+
+	registry[j.Name()] = j
+}
+
+// Get returns the job registered under name.
+func Get(name string) (Job, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	j, ok := registry[name]
+	return j, ok
+}
+
+// All returns every registered job, sorted by name.
+func All() []Job {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	all := make([]Job, 0, len(registry))
+	for _, j := range registry {
+		all = append(all, j)
+	}
+	sort.Slice(all, func(i, k int) bool { return all[i].Name() < all[k].Name() })
+
+	return all
+}
+
+// Info is a serializable description of a job.
+type Info struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Secrets     []string    `json:"secrets"`
+	Args        []ArgInfo   `json:"args,omitempty"`
+	Concurrency Concurrency `json:"concurrency"`
+	MaxAttempts int         `json:"max_attempts"`
+	Schedule    string      `json:"schedule,omitempty"`
+}
+
+// ArgInfo is a serializable description of an argument.
+type ArgInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Describe returns the Info for every registered job.
+func Describe() []Info {
+	all := All()
+	infos := make([]Info, len(all))
+	for i, j := range all {
+		infos[i] = Info{
+			Name:        j.Name(),
+			Description: j.Description(),
+			Secrets:     j.Secrets(),
+			Concurrency: j.Concurrency(),
+			MaxAttempts: j.Retry().MaxAttempts,
+			Schedule:    j.Schedule(),
+		}
+		for _, a := range j.Args() {
+			infos[i].Args = append(infos[i].Args, ArgInfo{Name: a.Name, Description: a.Description})
+		}
+	}
+
+	return infos
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/icco/cron/retry"
+)
+
+func noop(context.Context, *Config) error { return nil }
+
+func TestRegister(t *testing.T) {
+	j := New("test-register", "Does nothing.", noop)
+	Register(j)
+
+	got, ok := Get("test-register")
+	if !ok || got != j {
+		t.Fatalf("Get returned %v, %v", got, ok)
+	}
+	if _, ok := Get("test-unregistered"); ok {
+		t.Error("expected an unregistered job not to be found")
+	}
+
+	found := false
+	for _, info := range Describe() {
+		if info.Name == "test-register" {
+			found = info.Description == "Does nothing."
+		}
+	}
+	if !found {
+		t.Error("expected Describe to include the job")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a job twice to panic")
+		}
+	}()
+	Register(New("test-register", "", noop))
+}
+
+func TestNewDefaults(t *testing.T) {
+	j := New("test-defaults", "", noop)
+
+	if len(j.Secrets()) != 0 || len(j.Args()) != 0 || j.Schedule() != "" {
+		t.Errorf("expected no secrets, args or schedule, got %v, %v, %q", j.Secrets(), j.Args(), j.Schedule())
+	}
+	if j.Retry() != retry.Default {
+		t.Errorf("retry = %+v, want retry.Default", j.Retry())
+	}
+	if c := j.Concurrency(); c.Policy != Allow || c.Slots() != 0 {
+		t.Errorf("concurrency = %+v, want unlimited", c)
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	j := New("test-options", "", noop,
+		WithSecrets("A", "B"),
+		WithArgs(Arg{Name: "site"}),
+		WithRetry(retry.Never),
+		WithSchedule("0 4 * * *"),
+		WithConcurrency(Forbid, 0),
+	)
+
+	if got := j.Secrets(); len(got) != 2 || got[0] != "A" || got[1] != "B" {
+		t.Errorf("secrets = %v", got)
+	}
+	if got := j.Args(); len(got) != 1 || got[0].Name != "site" {
+		t.Errorf("args = %v", got)
+	}
+	if j.Retry() != retry.Never || j.Schedule() != "0 4 * * *" || j.Concurrency().Slots() != 1 {
+		t.Errorf("got retry %+v, schedule %q, concurrency %+v", j.Retry(), j.Schedule(), j.Concurrency())
+	}
+
+	if err := ValidateArgs(j, Args{"url": "https://food.natwelch.com/"}); err == nil {
+		t.Error("expected an unknown argument to be rejected")
+	}
+}
@@ -0,0 +1,208 @@
+// Package journal is a durable, append-only log of JSON records stored one
+// per line in a file. It is the storage under cron's file backed stores.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MinStale is how many superseded records a journal holds before
+// NeedsCompaction says it should be compacted.
+const MinStale = 1000
+
+// Journal is an append-only JSON lines file.
+type Journal struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+	n  int
+}
+
+// Open opens or creates the journal at path, creating parent directories as
+// needed.
+func Open(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create journal dir: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+
+	j := &Journal{path: path, f: f}
+	if err := j.endLine(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := Read(path, func(json.RawMessage) error {
+		j.n++
+		return nil
+	}); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// endLine ends a torn final write with a newline, so the next record starts
+// on a line of its own.
+func (j *Journal) endLine() error {
+	f, err := os.Open(j.path)
+	if err != nil {
+		return fmt.Errorf("open journal: %w", err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat journal: %w", err)
+	}
+	if fi.Size() == 0 {
+		return nil
+	}
+
+	b := make([]byte, 1)
+	if _, err := f.ReadAt(b, fi.Size()-1); err != nil {
+		return fmt.Errorf("read journal: %w", err)
+	}
+	if b[0] == '\n' {
+		return nil
+	}
+
+	if _, err := j.f.Write([]byte{'\n'}); err != nil {
+		return fmt.Errorf("end torn record: %w", err)
+	}
+
+	return nil
+}
+
+// Append writes v as a single line and syncs it to disk.
+func (j *Journal) Append(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal record: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("write record: %w", err)
+	}
+	j.n++
+
+	return j.f.Sync()
+}
+
+// Len is how many records are in the journal.
+func (j *Journal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.n
+}
+
+// NeedsCompaction reports whether a journal holding live current records has
+// enough superseded ones that it should be rewritten: more than it has live
+// ones, and at least MinStale.
+func (j *Journal) NeedsCompaction(live int) bool {
+	stale := j.Len() - live
+	return stale >= MinStale && stale > live
+}
+
+// Replay calls fn with every record in the order they were written. Lines
+// that are not valid JSON, like a torn final write, are skipped.
+func (j *Journal) Replay(fn func(json.RawMessage) error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return Read(j.path, fn)
+}
+
+// Read calls fn with every record in the journal at path without opening it
+// for writing, so it is safe while another process has it open.
+func Read(path string, fn func(json.RawMessage) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open journal: %w", err)
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for s.Scan() {
+		line := s.Bytes()
+		if len(line) == 0 || !json.Valid(line) {
+			continue
+		}
+
+		if err := fn(json.RawMessage(line)); err != nil {
+			return err
+		}
+	}
+
+	return s.Err()
+}
+
+// Rewrite atomically replaces the contents of the journal with records. Use it
+// to compact a journal once older records are superseded.
+func (j *Journal) Rewrite(records []any) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temp journal: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			tmp.Close()
+			return fmt.Errorf("write record: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		return fmt.Errorf("replace journal: %w", err)
+	}
+
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("reopen journal: %w", err)
+	}
+	j.f.Close()
+	j.f = f
+	j.n = len(records)
+
+	return nil
+}
+
+// Close closes the journal.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.f.Close()
+}
@@ -0,0 +1,111 @@
+package journal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type rec struct {
+	ID string `json:"id"`
+}
+
+func replay(t *testing.T, j *Journal) []string {
+	t.Helper()
+
+	var ids []string
+	if err := j.Replay(func(b json.RawMessage) error {
+		var r rec
+		if err := json.Unmarshal(b, &r); err != nil {
+			return err
+		}
+		ids = append(ids, r.ID)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return ids
+}
+
+func TestAppendReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", "j.jsonl")
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if err := j.Append(rec{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	if got := replay(t, j); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("replayed %v, want [a b c]", got)
+	}
+	if j.Len() != 3 {
+		t.Errorf("Len = %d, want 3", j.Len())
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	j, err := Open(filepath.Join(t.TempDir(), "j.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	for range MinStale + 1 {
+		if err := j.Append(rec{ID: "a"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !j.NeedsCompaction(1) {
+		t.Errorf("expected %d records with one live to need compaction", j.Len())
+	}
+
+	if err := j.Rewrite([]any{rec{ID: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Append(rec{ID: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := replay(t, j); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("replayed %v after compaction, want [a b]", got)
+	}
+	if j.Len() != 2 || j.NeedsCompaction(2) {
+		t.Errorf("Len = %d, want 2 and no compaction needed", j.Len())
+	}
+}
+
+func TestTornWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "j.jsonl")
+	if err := os.WriteFile(path, []byte(`{"id":"a"}`+"\n"+`{"id":"b`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if err := j.Append(rec{ID: "c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := replay(t, j); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("replayed %v, want the torn record skipped and [a c]", got)
+	}
+}
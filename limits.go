@@ -0,0 +1,61 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/icco/cron/jobs"
+)
+
+// limiter enforces each job's concurrency policy. The zero value is ready to
+// use.
+type limiter struct {
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+func (l *limiter) slotsFor(j jobs.Job) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.slots == nil {
+		l.slots = map[string]chan struct{}{}
+	}
+
+	c, ok := l.slots[j.Name()]
+	if !ok {
+		c = make(chan struct{}, j.Concurrency().Slots())
+		l.slots[j.Name()] = c
+	}
+
+	return c
+}
+
+// acquire takes a slot for j, waiting if the job queues. The returned func
+// gives the slot back.
+func (l *limiter) acquire(ctx context.Context, j jobs.Job) (func(), error) {
+	c := j.Concurrency()
+	if c.Slots() == 0 {
+		return func() {}, nil
+	}
+
+	slots := l.slotsFor(j)
+	release := func() { <-slots }
+
+	if c.Policy == jobs.Queue {
+		select {
+		case slots <- struct{}{}:
+			return release, nil
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for %q to finish: %w", j.Name(), ctx.Err())
+		}
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	default:
+		return nil, fmt.Errorf("%w: %d run(s) of %q already in progress (policy %s)", ErrSkipped, cap(slots), j.Name(), c.Policy)
+	}
+}
@@ -0,0 +1,80 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/icco/cron/jobs"
+)
+
+func TestLimiter(t *testing.T) {
+	noop := func(context.Context, *jobs.Config) error { return nil }
+	ctx := context.Background()
+
+	t.Run("forbid", func(t *testing.T) {
+		var l limiter
+		j := jobs.New("forbid", "", noop, jobs.WithConcurrency(jobs.Forbid, 0))
+
+		release, err := l.acquire(ctx, j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.acquire(ctx, j); !errors.Is(err, ErrSkipped) {
+			t.Errorf("expected second run to be skipped, got %+v", err)
+		}
+
+		release()
+		if _, err := l.acquire(ctx, j); err != nil {
+			t.Errorf("expected run after release to start, got %+v", err)
+		}
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		var l limiter
+		j := jobs.New("allow", "", noop, jobs.WithConcurrency(jobs.Allow, 2))
+
+		for i := 0; i < 2; i++ {
+			if _, err := l.acquire(ctx, j); err != nil {
+				t.Fatalf("run %d: %+v", i, err)
+			}
+		}
+		if _, err := l.acquire(ctx, j); !errors.Is(err, ErrSkipped) {
+			t.Errorf("expected third run to be skipped, got %+v", err)
+		}
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		var l limiter
+		j := jobs.New("queue", "", noop, jobs.WithConcurrency(jobs.Queue, 0))
+
+		release, err := l.acquire(ctx, j)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		done := make(chan error)
+		go func() {
+			_, err := l.acquire(ctx, j)
+			done <- err
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("expected second run to wait")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		release()
+		if err := <-done; err != nil {
+			t.Errorf("expected queued run to start, got %+v", err)
+		}
+
+		tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := l.acquire(tctx, j); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected waiting to stop with the context, got %+v", err)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package cron
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/retry"
+)
+
+// Message is a request to run a job.
+type Message struct {
+	// ID is the Pub/Sub message ID, if the message came from Pub/Sub.
+	ID string `json:"id,omitempty"`
+
+	Job  string    `json:"job"`
+	Args jobs.Args `json:"args,omitempty"`
+
+	// DryRun runs the job with its writes recorded instead of made.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// ParseMessage parses a message payload like {"job":"spider","url":"..."}.
+// "dry_run" may be true, as a bool or a string, to ask for a dry run. Every
+// other key besides "job" is an argument to the job.
+func ParseMessage(data []byte) (*Message, error) {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, retry.Permanent(fmt.Errorf("parse json: %w", err))
+	}
+
+	msg := &Message{Args: jobs.Args{}}
+	if d, ok := raw["dry_run"]; ok {
+		var b bool
+		if err := json.Unmarshal(d, &b); err != nil {
+			var s string
+			if err := json.Unmarshal(d, &s); err != nil {
+				return nil, retry.Permanent(fmt.Errorf("parse dry_run: %w", err))
+			}
+			if b, err = strconv.ParseBool(s); err != nil {
+				return nil, retry.Permanent(fmt.Errorf("parse dry_run: %w", err))
+			}
+		}
+		msg.DryRun = b
+		delete(raw, "dry_run")
+	}
+
+	for k, v := range raw {
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return nil, retry.Permanent(fmt.Errorf("parse json: %q must be a string: %w", k, err))
+		}
+
+		if k == "job" {
+			msg.Job = s
+			continue
+		}
+		msg.Args[k] = s
+	}
+
+	return msg, nil
+}
+
+// Payload encodes msg the way ParseMessage reads it, with args as top level
+// keys.
+func (msg *Message) Payload() ([]byte, error) {
+	raw := map[string]any{"job": msg.Job}
+	for k, v := range msg.Args {
+		raw[k] = v
+	}
+	if msg.DryRun {
+		raw["dry_run"] = true
+	}
+
+	return json.Marshal(raw)
+}
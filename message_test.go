@@ -0,0 +1,56 @@
+package cron
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/icco/cron/jobs"
+)
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    *Message
+		wantErr bool
+	}{
+		{`{"job": "spider", "url": "https://food.natwelch.com/"}`, &Message{Job: "spider", Args: jobs.Args{"url": "https://food.natwelch.com/"}}, false},
+		{`{"job": "code", "dry_run": true}`, &Message{Job: "code", Args: jobs.Args{}, DryRun: true}, false},
+		{`{"job": "code", "dry_run": "1"}`, &Message{Job: "code", Args: jobs.Args{}, DryRun: true}, false},
+		{`{"job": "code", "dry_run": false}`, &Message{Job: "code", Args: jobs.Args{}}, false},
+		{`{"job": "code", "dry_run": "maybe"}`, nil, true},
+		{`{"job": "code", "from": 20240101}`, nil, true},
+		{`not json`, nil, true},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseMessage([]byte(tc.data))
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseMessage(%s): expected error %v, got %v", tc.data, tc.wantErr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseMessage(%s) = %+v, want %+v", tc.data, got, tc.want)
+		}
+	}
+}
+
+func TestMessagePayload(t *testing.T) {
+	for _, msg := range []*Message{
+		{Job: "minute", Args: jobs.Args{}},
+		{Job: "code", Args: jobs.Args{"from": "2024-01-01", "to": "2024-01-07"}},
+		{Job: "update-triggers", Args: jobs.Args{}, DryRun: true},
+	} {
+		b, err := msg.Payload()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := ParseMessage(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, msg) {
+			t.Errorf("ParseMessage(%s) = %+v, want %+v", b, got, msg)
+		}
+	}
+}
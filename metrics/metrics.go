@@ -0,0 +1,132 @@
+// Package metrics holds the Prometheus metrics cron exports at /metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// JobRuns counts finished job runs by outcome.
+	JobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cron_job_runs_total",
+		Help: "Job runs by job and outcome.",
+	}, []string{"job", "status"})
+
+	// JobDuration is how long job runs take, including retries.
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cron_job_duration_seconds",
+		Help:    "How long job runs take, including retries.",
+		Buckets: []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	}, []string{"job"})
+
+	// JobsInFlight is the number of job runs that have not finished.
+	JobsInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cron_jobs_in_flight",
+		Help: "Job runs that have started but not finished.",
+	}, []string{"job"})
+
+	// PubSubMessages counts Pub/Sub messages received, acked and nacked.
+	PubSubMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cron_pubsub_messages_total",
+		Help: "Pub/Sub messages by event: received, acked or nacked.",
+	}, []string{"event"})
+
+	// Duplicates counts messages that were dropped as duplicates.
+	Duplicates = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cron_dedupe_duplicates_total",
+		Help: "Messages dropped because their ID was already handled.",
+	})
+
+	// HTTPRequests counts outbound HTTP requests by the service they went
+	// to, as named by upstream, and status code, or "error" if there was no
+	// response.
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cron_http_client_requests_total",
+		Help: "Outbound HTTP requests by upstream and status code.",
+	}, []string{"upstream", "code"})
+
+	// HTTPDuration is how long outbound HTTP requests take by service.
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cron_http_client_request_duration_seconds",
+		Help:    "How long outbound HTTP requests take by upstream.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"upstream"})
+)
+
+// Pub/Sub message events.
+const (
+	Received = "received"
+	Acked    = "acked"
+	Nacked   = "nacked"
+)
+
+// WatchOutbox exports how many GraphQL writes are waiting in the outbox and
+// how old the oldest is, both read from stats when scraped.
+func WatchOutbox(stats func() (depth int, oldest time.Time)) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "cron_outbox_depth",
+		Help: "GraphQL writes waiting in the outbox.",
+	}, func() float64 {
+		n, _ := stats()
+		return float64(n)
+	})
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "cron_outbox_oldest_age_seconds",
+		Help: "How long the oldest GraphQL write in the outbox has waited.",
+	}, func() float64 {
+		_, oldest := stats()
+		if oldest.IsZero() {
+			return 0
+		}
+		return time.Since(oldest).Seconds()
+	})
+}
+
+// upstreams names the services jobs talk to. A host is matched by a suffix
+// that is the whole host or a parent domain of it.
+var upstreams = []struct{ suffix, name string }{
+	{"graphql.natwelch.com", "graphql"},
+	{"code.natwelch.com", "code"},
+	{"cacophony.natwelch.com", "cacophony"},
+	{"github.com", "github"},
+	{"githubarchive.org", "githubarchive"},
+	{"coinbase.com", "coinbase"},
+	{"openweathermap.org", "openweathermap"},
+	{"pinboard.in", "pinboard"},
+	{"goodreads.com", "goodreads"},
+	{"twitter.com", "twitter"},
+	{"newyork.welch.io", "adsb"},
+}
+
+// upstream returns the name of the service at host, or "other" for hosts
+// that aren't one of ours, like the sites the spider follows links to, so
+// they can't blow up the number of series.
+func upstream(host string) string {
+	for _, u := range upstreams {
+		if host == u.suffix || strings.HasSuffix(host, "."+u.suffix) {
+			return u.name
+		}
+	}
+
+	return "other"
+}
+
+// ObserveHTTP counts and times a finished outbound request. It fits
+// shared.HTTPHook.
+func ObserveHTTP(req *http.Request, resp *http.Response, err error, took time.Duration) {
+	name := upstream(req.URL.Hostname())
+	HTTPDuration.WithLabelValues(name).Observe(took.Seconds())
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	HTTPRequests.WithLabelValues(name, code).Inc()
+}
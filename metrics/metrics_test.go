@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestUpstream(t *testing.T) {
+	for host, want := range map[string]string{
+		"graphql.natwelch.com":   "graphql",
+		"api.github.com":         "github",
+		"data.githubarchive.org": "githubarchive",
+		"api.coinbase.com":       "coinbase",
+		"api.openweathermap.org": "openweathermap",
+		"food.natwelch.com":      "other",
+		"notgithub.com":          "other",
+		"127.0.0.1":              "other",
+	} {
+		if got := upstream(host); got != want {
+			t.Errorf("upstream(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestObserveHTTP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://api.coinbase.com/v2/prices/BTC-USD/spot", nil)
+	ObserveHTTP(req, &http.Response{StatusCode: http.StatusOK}, nil, time.Second)
+	ObserveHTTP(req, &http.Response{StatusCode: http.StatusOK}, nil, time.Second)
+	ObserveHTTP(req, nil, errors.New("connection reset"), time.Second)
+	ObserveHTTP(httptest.NewRequest(http.MethodGet, "https://example.com/", nil), &http.Response{StatusCode: http.StatusNotFound}, nil, time.Second)
+
+	if got := testutil.ToFloat64(HTTPRequests.WithLabelValues("coinbase", "200")); got != 2 {
+		t.Errorf("200s = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(HTTPRequests.WithLabelValues("coinbase", "error")); got != 1 {
+		t.Errorf("errors = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(HTTPRequests.WithLabelValues("other", "404")); got != 1 {
+		t.Errorf("other 404s = %v, want 1", got)
+	}
+}
@@ -0,0 +1,108 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/icco/cron/retry"
+	"go.uber.org/zap"
+)
+
+// SendFunc sends a request from the outbox.
+type SendFunc func(ctx context.Context, query string, vars map[string]any) error
+
+// Flusher sends what is in an outbox, backing off items that keep failing.
+type Flusher struct {
+	Log    *zap.SugaredLogger
+	Outbox *Outbox
+	Send   SendFunc
+
+	// Interval is how often the outbox is checked. Defaults to a minute.
+	Interval time.Duration
+
+	// Backoff decides how long an item waits after each failed attempt. Its
+	// MaxAttempts is ignored, items are kept until they are sent or rejected.
+	Backoff retry.Policy
+}
+
+// Flush sends every item that is due, oldest first, and returns how many were
+// sent. Items the API rejects are dropped, since sending them again won't
+// help. The first item that fails for any other reason stops the flush, as
+// the API is probably still down. Once items are sent or fail, the outbox is
+// compacted, so retrying while the API is down doesn't grow its journal.
+func (f *Flusher) Flush(ctx context.Context) (sent int, err error) {
+	now := f.Outbox.now()
+	changed := false
+	defer func() {
+		if !changed {
+			return
+		}
+		if cerr := f.Outbox.Compact(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	for _, it := range f.Outbox.List() {
+		if it.NextAttempt.After(now) {
+			continue
+		}
+
+		vars := make(map[string]any, len(it.Variables))
+		for k, v := range it.Variables {
+			vars[k] = json.RawMessage(v)
+		}
+
+		err := f.Send(ctx, it.Query, vars)
+		changed = true
+		switch {
+		case err == nil:
+			sent++
+			if err := f.Outbox.Done(it.ID); err != nil {
+				return sent, err
+			}
+		case retry.ClassOf(err) == retry.ClassPermanent && ctx.Err() == nil:
+			f.Log.Errorw("dropping rejected outbox item", "item", it, zap.Error(err))
+			if err := f.Outbox.Done(it.ID); err != nil {
+				return sent, err
+			}
+		default:
+			next := now.Add(f.Backoff.Backoff(it.Attempts + 1))
+			if reset := retry.ResetOf(err); reset.After(next) {
+				next = reset
+			}
+			if ferr := f.Outbox.Failed(it.ID, err, next); ferr != nil {
+				return sent, ferr
+			}
+			return sent, err
+		}
+	}
+
+	return sent, nil
+}
+
+// Run flushes the outbox every Interval until ctx is done.
+func (f *Flusher) Run(ctx context.Context) error {
+	interval := f.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		n, err := f.Flush(ctx)
+		if n > 0 {
+			f.Log.Infow("flushed outbox", "sent", n, "pending", f.Outbox.Stats().Depth)
+		}
+		if err != nil {
+			f.Log.Warnw("could not flush outbox", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
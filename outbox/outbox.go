@@ -0,0 +1,205 @@
+// Package outbox keeps GraphQL writes that could not be sent on disk, so they
+// can be sent once the API is back instead of being lost.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/icco/cron/journal"
+)
+
+// Item is a request waiting to be sent.
+type Item struct {
+	ID        string                     `json:"id"`
+	Query     string                     `json:"query"`
+	Variables map[string]json.RawMessage `json:"variables,omitempty"`
+	Added     time.Time                  `json:"added"`
+
+	// Attempts is how many times sending the item has failed.
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	NextAttempt time.Time `json:"next_attempt,omitzero"`
+}
+
+// Stats summarizes what is waiting in an outbox.
+type Stats struct {
+	Depth  int   `json:"depth"`
+	Oldest *Item `json:"oldest,omitempty"`
+}
+
+// record is a line in the journal. Sent items are written as tombstones.
+type record struct {
+	Item *Item  `json:"item,omitempty"`
+	Done string `json:"done,omitempty"`
+}
+
+// Outbox is a queue of requests backed by a journal on local disk.
+type Outbox struct {
+	journal *journal.Journal
+	now     func() time.Time
+
+	mu    sync.RWMutex
+	items map[string]*Item
+}
+
+// Open opens or creates an outbox at path.
+func Open(path string) (*Outbox, error) {
+	j, err := journal.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Outbox{journal: j, now: time.Now, items: map[string]*Item{}}
+	if err := j.Replay(func(b json.RawMessage) error {
+		var r record
+		if err := json.Unmarshal(b, &r); err != nil {
+			return fmt.Errorf("decode outbox item: %w", err)
+		}
+
+		if r.Item != nil {
+			o.items[r.Item.ID] = r.Item
+		}
+		if r.Done != "" {
+			delete(o.items, r.Done)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := o.Compact(); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// Compact rewrites the journal with just the items that are waiting, dropping
+// the records of ones that were sent and of earlier failed attempts.
+func (o *Outbox) Compact() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	all := o.sorted()
+	records := make([]any, len(all))
+	for i, it := range all {
+		records[i] = record{Item: it}
+	}
+
+	return o.journal.Rewrite(records)
+}
+
+// Enqueue stores a request to send later.
+func (o *Outbox) Enqueue(ctx context.Context, query string, vars map[string]any) error {
+	it := &Item{
+		ID:        uuid.NewString(),
+		Query:     query,
+		Variables: make(map[string]json.RawMessage, len(vars)),
+		Added:     o.now(),
+	}
+	for k, v := range vars {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("encode variable %q: %w", k, err)
+		}
+		it.Variables[k] = b
+	}
+
+	return o.put(it)
+}
+
+func (o *Outbox) put(it *Item) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.journal.Append(record{Item: it}); err != nil {
+		return fmt.Errorf("append outbox item: %w", err)
+	}
+
+	cp := *it
+	o.items[it.ID] = &cp
+	return nil
+}
+
+// List returns every item, oldest first.
+func (o *Outbox) List() []*Item {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	all := o.sorted()
+	out := make([]*Item, len(all))
+	for i, it := range all {
+		cp := *it
+		out[i] = &cp
+	}
+
+	return out
+}
+
+// Stats returns how many items are waiting and the oldest of them.
+func (o *Outbox) Stats() Stats {
+	all := o.List()
+	s := Stats{Depth: len(all)}
+	if len(all) > 0 {
+		s.Oldest = all[0]
+	}
+
+	return s
+}
+
+// Done removes a sent item.
+func (o *Outbox) Done(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.items[id]; !ok {
+		return nil
+	}
+
+	if err := o.journal.Append(record{Done: id}); err != nil {
+		return fmt.Errorf("append done: %w", err)
+	}
+
+	delete(o.items, id)
+	return nil
+}
+
+// Failed records a failed attempt at sending an item, which is tried again
+// at next.
+func (o *Outbox) Failed(id string, err error, next time.Time) error {
+	o.mu.RLock()
+	it, ok := o.items[id]
+	o.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	cp := *it
+	cp.Attempts++
+	cp.LastError = err.Error()
+	cp.NextAttempt = next
+
+	return o.put(&cp)
+}
+
+// Close closes the underlying journal.
+func (o *Outbox) Close() error {
+	return o.journal.Close()
+}
+
+// sorted returns every item, oldest first. Callers must hold mu.
+func (o *Outbox) sorted() []*Item {
+	all := make([]*Item, 0, len(o.items))
+	for _, it := range o.items {
+		all = append(all, it)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Added.Before(all[j].Added) })
+
+	return all
+}
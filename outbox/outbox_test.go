@@ -0,0 +1,161 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/icco/cron/retry"
+	"go.uber.org/zap"
+)
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	o, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	o.now = func() time.Time { now = now.Add(time.Second); return now }
+
+	for _, k := range []string{"a", "b", "c"} {
+		if err := o.Enqueue(ctx, "mutation "+k, map[string]any{"s": map[string]string{"key": k}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := o.List()
+	if len(list) != 3 || list[0].Query != "mutation a" {
+		t.Fatalf("expected three items, oldest first, got %+v", list)
+	}
+	if got := string(list[0].Variables["s"]); got != `{"key":"a"}` {
+		t.Errorf("variables = %s", got)
+	}
+
+	if err := o.Done(list[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Failed(list[1].ID, errors.New("down"), now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	s := o.Stats()
+	if s.Depth != 2 || s.Oldest == nil || s.Oldest.ID != list[1].ID {
+		t.Fatalf("expected two items with b oldest after reopening, got %+v", s)
+	}
+	if s.Oldest.Attempts != 1 || s.Oldest.LastError != "down" {
+		t.Errorf("expected the failed attempt to be kept, got %+v", s.Oldest)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	ctx := context.Background()
+	o, err := Open(filepath.Join(t.TempDir(), "outbox.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	o.now = func() time.Time { return now }
+	for _, q := range []string{"ok", "rejected", "down", "later"} {
+		if err := o.Enqueue(ctx, q, nil); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	var sent []string
+	down := true
+	f := &Flusher{
+		Log:     zap.NewNop().Sugar(),
+		Outbox:  o,
+		Backoff: retry.Policy{Initial: time.Minute, Multiplier: 2},
+		Send: func(_ context.Context, query string, _ map[string]any) error {
+			sent = append(sent, query)
+			switch {
+			case query == "rejected":
+				return retry.Permanent(errors.New("bad input"))
+			case query == "down" && down:
+				return retry.Transient(errors.New("503"))
+			}
+			return nil
+		},
+	}
+
+	n, err := f.Flush(ctx)
+	if err == nil || n != 1 {
+		t.Fatalf("expected to stop after one sent item, got %d, %v", n, err)
+	}
+	if got := o.Stats(); got.Depth != 2 || got.Oldest.Query != "down" || !got.Oldest.NextAttempt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("expected the failed item to back off for a minute, got %+v", got.Oldest)
+	}
+
+	// Nothing is due until the backoff runs out.
+	sent = nil
+	if _, err := f.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || sent[0] != "later" {
+		t.Errorf("expected only the item that isn't backing off to be sent, got %v", sent)
+	}
+
+	down = false
+	now = now.Add(time.Minute)
+	if n, err := f.Flush(ctx); err != nil || n != 1 {
+		t.Fatalf("expected the item to be sent once the API is back, got %d, %v", n, err)
+	}
+	if d := o.Stats().Depth; d != 0 {
+		t.Errorf("expected an empty outbox, got %d items", d)
+	}
+}
+
+func TestFlushCompacts(t *testing.T) {
+	ctx := context.Background()
+	o, err := Open(filepath.Join(t.TempDir(), "outbox.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	for _, q := range []string{"a", "b"} {
+		if err := o.Enqueue(ctx, q, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := &Flusher{
+		Log:    zap.NewNop().Sugar(),
+		Outbox: o,
+		Send: func(context.Context, string, map[string]any) error {
+			return retry.Transient(errors.New("503"))
+		},
+	}
+
+	// With no backoff, every flush is another failed attempt at the first
+	// item.
+	for range 10 {
+		if _, err := f.Flush(ctx); err == nil {
+			t.Fatal("expected the flush to fail while the API is down")
+		}
+	}
+	if n := o.journal.Len(); n != 2 {
+		t.Errorf("journal has %d records for two waiting items, want 2", n)
+	}
+	if got := o.Stats(); got.Depth != 2 || got.Oldest.Attempts != 10 {
+		t.Errorf("expected both items kept with ten attempts at the first, got %+v", got)
+	}
+}
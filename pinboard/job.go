@@ -0,0 +1,27 @@
+package pinboard
+
+import (
+	"context"
+
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/shared"
+)
+
+func init() {
+	jobs.Register(jobs.New(
+		"pinboard",
+		"Uploads links pinned in the last 30 minutes to graphql.",
+		func(ctx context.Context, cfg *jobs.Config) error {
+			p := &Pinboard{
+				Config:  cfg.Config,
+				Token:   cfg.Secret("PINBOARD_TOKEN"),
+				GraphQL: shared.NewGraphQL(cfg.Config, cfg.Secret("GQL_TOKEN")),
+			}
+
+			return p.UpdatePins(ctx)
+		},
+		jobs.WithSecrets("GQL_TOKEN", "PINBOARD_TOKEN"),
+		jobs.WithConcurrency(jobs.Forbid, 0),
+		jobs.WithSchedule("*/30 * * * *"),
+	))
+}
@@ -2,13 +2,13 @@ package pinboard
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/icco/cron/shared"
-	"github.com/machinebox/graphql"
+	gql "github.com/icco/graphql"
 	"github.com/zachlatta/pin"
 	"go.uber.org/zap"
 )
@@ -17,68 +17,71 @@ import (
 type Pinboard struct {
 	shared.Config
 
-	Token        string
-	GraphQLToken string
+	Token   string
+	GraphQL *shared.GraphQL
 }
 
-// UpdatePins gets and uploads pinned websites to graphql.
-func (p *Pinboard) UpdatePins(ctx context.Context) error {
+// lookback is how far back UpdatePins looks for pins. It matches how often
+// the job is scheduled.
+const lookback = 30 * time.Minute
+
+// window returns the range of pins to get at now. The pinboard client
+// formats times as UTC without converting them, so they are converted here.
+func window(now time.Time) (from, to time.Time) {
+	to = now.UTC()
+	return to.Add(-lookback), to
+}
+
+// GetPins gets the pins added since the job last ran.
+func (p *Pinboard) GetPins(ctx context.Context) ([]*pin.Post, error) {
 	tokenParts := strings.Split(p.Token, ":")
 	if len(tokenParts) != 2 {
-		return fmt.Errorf("Pinboard Token is malformed")
+		return nil, fmt.Errorf("Pinboard Token is malformed")
 	}
-	pinClient := pin.NewClient(nil, &pin.AuthToken{Username: tokenParts[0], Token: tokenParts[1]})
+	pinClient := pin.NewClient(p.HTTPClient(), &pin.AuthToken{Username: tokenParts[0], Token: tokenParts[1]})
 
 	tags := []string{}
 	start := 0   // 0 means most recent
 	results := 0 // 0 means all
 
-	// Only get pins from the last 30m
-	thirtyMin, err := time.ParseDuration("-30m")
+	from, to := window(p.Now())
+	posts, _, err := pinClient.Posts.All(tags, start, results, &from, &to)
 	if err != nil {
-		p.Log.Errorw("time parsing", zap.Error(err))
-		return err
+		p.Log.Errorw("failure talking to pinboard", zap.Error(err))
+		return nil, err
 	}
-	from := time.Now().Add(thirtyMin)
-	to := time.Now()
 
-	posts, _, err := pinClient.Posts.All(tags, start, results, &from, &to)
+	return posts, nil
+}
+
+// UpdatePins gets and uploads pinned websites to graphql.
+func (p *Pinboard) UpdatePins(ctx context.Context) error {
+	posts, err := p.GetPins(ctx)
 	if err != nil {
-		p.Log.Errorw("failure talking to pinboard", zap.Error(err))
 		return err
 	}
 
-	gqlClient := graphql.NewClient("https://graphql.natwelch.com/graphql")
-	mut := `
-    mutation ($title: String!, $tags: [String!]!, $uri: URI!, $desc: String!, $time: Time!) {
-      upsertLink(
-        input: {
-          title: $title
-          tags: $tags
-          uri: $uri
-          description: $desc
-          created: $time
-        }
-      ) {
-        id
-      }
-    }
-  `
-	for _, po := range posts {
-		req := graphql.NewRequest(mut)
-		req.Var("title", po.Title)
-		req.Var("tags", po.Tags)
-		req.Var("uri", po.URL)
-		req.Var("desc", po.Description)
-		req.Var("time", po.Time)
-		req.Header.Add("X-API-AUTH", p.GraphQLToken)
+	upserts := make([]shared.Upsert, len(posts))
+	for i, po := range posts {
+		upserts[i] = shared.LinkUpsert(gql.NewLink{
+			Title:       po.Title,
+			URI:         *gql.NewURI(po.URL),
+			Description: po.Description,
+			Tags:        po.Tags,
+			Created:     po.Time,
+		})
+	}
 
-		var resp json.RawMessage
-		if err := gqlClient.Run(ctx, req, &resp); err != nil {
-			p.Log.Errorw("graphql error on link upsert", zap.Error(err), "request", req)
-			return err
+	err = p.GraphQL.Batch(ctx, upserts)
+	var be *shared.BatchError
+	if errors.As(err, &be) {
+		for _, i := range be.Indexes() {
+			p.Log.Errorw("graphql error on link upsert", zap.Error(be.Failed[i]), "link", posts[i].URL)
 		}
+		// The rest were saved, and sending these again won't get them
+		// accepted, so they don't fail the run.
+		err = nil
 	}
 
-	return nil
+	return err
 }
@@ -0,0 +1,96 @@
+package pinboard
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/icco/cron/gqltest"
+	"github.com/icco/cron/replay"
+	"github.com/icco/cron/shared"
+	"go.uber.org/zap"
+)
+
+func TestUpdatePins(t *testing.T) {
+	srv := gqltest.NewServer(t)
+	srv.Upstream("api.pinboard.in", gqltest.File(t, "testdata/posts.xml"))
+
+	if err := srv.Run(t, "pinboard", map[string]string{"PINBOARD_TOKEN": "icco:token"}); err != nil {
+		t.Fatal(err)
+	}
+
+	gqltest.Golden(t, "testdata/pinboard.golden.json", srv.Mutations())
+}
+
+func TestUpdatePinsRejected(t *testing.T) {
+	srv := gqltest.NewServer(t)
+	srv.Upstream("api.pinboard.in", gqltest.File(t, "testdata/posts.xml"))
+	srv.Reject("upsertLink", "go.dev")
+
+	// The rejected link is logged, and the others still saved.
+	if err := srv.Run(t, "pinboard", map[string]string{"PINBOARD_TOKEN": "icco:token"}); err != nil {
+		t.Fatalf("expected a rejected link not to fail the run, got %v", err)
+	}
+	if got := srv.Mutations(); len(got) != 1 {
+		t.Errorf("expected the other link to be saved, got %+v", got)
+	}
+}
+
+func TestGetPins(t *testing.T) {
+	token := replay.Secret(t, "PINBOARD_TOKEN", "icco:token")
+	p := &Pinboard{
+		Config: shared.Config{
+			Log:   zap.NewNop().Sugar(),
+			Clock: shared.NewFakeClock(time.Date(2024, 1, 2, 3, 10, 0, 0, time.UTC)),
+			HTTP:  &shared.HTTP{Base: replay.New(t, "testdata/pinboard.replay.json", token)},
+		},
+		Token: token,
+	}
+
+	posts, err := p.GetPins(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var urls []string
+	for _, post := range posts {
+		urls = append(urls, post.URL)
+	}
+	want := []string{"https://go.dev/blog/loopvar-preview", "https://www.recurse.com/blog/"}
+	if !slices.Equal(urls, want) {
+		t.Errorf("urls = %q, want %q", urls, want)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		from, to string
+	}{
+		{"utc", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "2024-01-02T02:34:05Z", "2024-01-02T03:04:05Z"},
+		{"across midnight UTC", time.Date(2024, 1, 2, 0, 10, 0, 0, time.UTC), "2024-01-01T23:40:00Z", "2024-01-02T00:10:00Z"},
+		{"local clock", time.Date(2024, 1, 2, 3, 4, 5, 0, ny), "2024-01-02T07:34:05Z", "2024-01-02T08:04:05Z"},
+		{"across spring forward", time.Date(2024, 3, 10, 3, 10, 0, 0, ny), "2024-03-10T06:40:00Z", "2024-03-10T07:10:00Z"},
+		{"across fall back", time.Date(2024, 11, 3, 1, 10, 0, 0, ny).Add(time.Hour), "2024-11-03T05:40:00Z", "2024-11-03T06:10:00Z"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			from, to := window(tc.now)
+			if got := from.Format(time.RFC3339); got != tc.from {
+				t.Errorf("from = %s, want %s", got, tc.from)
+			}
+			if got := to.Format(time.RFC3339); got != tc.to {
+				t.Errorf("to = %s, want %s", got, tc.to)
+			}
+		})
+	}
+}
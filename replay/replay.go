@@ -0,0 +1,251 @@
+// Package replay records HTTP responses from real services once and replays
+// them in tests, so fetchers can be tested without the network. Secrets are
+// redacted before anything is written to disk.
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+var record = flag.Bool("record", false, "record HTTP fixtures from the real services instead of replaying them")
+
+// Redacted replaces secrets in fixtures.
+const Redacted = "REDACTED"
+
+var (
+	// SensitiveParams are query parameters that are always redacted.
+	SensitiveParams = []string{"access_token", "api_key", "apikey", "appid", "auth_token", "key", "token"}
+
+	// SensitiveHeaders are response headers that are never recorded.
+	SensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Auth", "X-Api-Key"}
+)
+
+// Interaction is a recorded request and the response to it.
+type Interaction struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	RequestBody string `json:"request_body,omitempty"`
+
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+
+	// Body holds text responses and Binary everything else.
+	Body   string `json:"body,omitempty"`
+	Binary []byte `json:"binary,omitempty"`
+}
+
+// Transport replays the interactions in a fixture file or, when tests are run
+// with -record, sends requests to the real services and records them.
+type Transport struct {
+	path      string
+	recording bool
+	base      http.RoundTripper
+	secrets   []string
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// New returns a Transport for the fixture at path. Any of secrets found in
+// requests or responses are redacted along with SensitiveParams and
+// SensitiveHeaders. When recording, the fixture is written when the test
+// ends.
+func New(t testing.TB, path string, secrets ...string) *Transport {
+	t.Helper()
+
+	rt := &Transport{path: path, recording: *record, base: http.DefaultTransport}
+	for _, s := range secrets {
+		if s != "" {
+			rt.secrets = append(rt.secrets, s)
+		}
+	}
+
+	if rt.recording {
+		t.Cleanup(func() {
+			if err := rt.save(); err != nil {
+				t.Errorf("save fixture: %v", err)
+			}
+		})
+		return rt
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -record to create it)", err)
+	}
+	if err := json.Unmarshal(b, &rt.interactions); err != nil {
+		t.Fatalf("decode fixture %s: %v", path, err)
+	}
+	rt.used = make([]bool, len(rt.interactions))
+
+	return rt
+}
+
+// Secret returns the environment variable key when recording, so requests
+// reach the real service, and fake otherwise. Pass it to New so it is
+// redacted.
+func Secret(t testing.TB, key, fake string) string {
+	t.Helper()
+
+	if !*record {
+		return fake
+	}
+
+	v := os.Getenv(key)
+	if v == "" {
+		t.Fatalf("%s must be set to record fixtures", key)
+	}
+
+	return v
+}
+
+// RoundTrip replays the first unused interaction that matches req, or
+// records one.
+func (rt *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody string
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("replay: read request body: %w", err)
+		}
+		reqBody = string(b)
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
+	if rt.recording {
+		return rt.record(req, reqBody)
+	}
+
+	u := rt.redactURL(req.URL)
+	body := rt.redact(reqBody)
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for i, in := range rt.interactions {
+		if rt.used[i] || in.Method != req.Method || in.URL != u || in.RequestBody != body {
+			continue
+		}
+		rt.used[i] = true
+
+		b := []byte(in.Body)
+		if in.Binary != nil {
+			b = in.Binary
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			StatusCode:    in.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(b)),
+			ContentLength: int64(len(b)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("replay: no response recorded in %s for %s %s (run with -record to add it)", rt.path, req.Method, u)
+}
+
+func (rt *Transport) record(req *http.Request, reqBody string) (*http.Response, error) {
+	resp, err := rt.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("replay: read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+
+	in := Interaction{
+		Method:      req.Method,
+		URL:         rt.redactURL(req.URL),
+		RequestBody: rt.redact(reqBody),
+		Status:      resp.StatusCode,
+		Header:      http.Header{},
+	}
+	for k, v := range resp.Header {
+		if slices.Contains(SensitiveHeaders, http.CanonicalHeaderKey(k)) {
+			continue
+		}
+		for _, s := range v {
+			in.Header.Add(k, rt.redact(s))
+		}
+	}
+	if utf8.Valid(b) {
+		in.Body = rt.redact(string(b))
+	} else {
+		in.Binary = b
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.interactions = append(rt.interactions, in)
+
+	return resp, nil
+}
+
+func (rt *Transport) save() error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if len(rt.interactions) == 0 {
+		return errors.New("no requests were made")
+	}
+
+	b, err := json.MarshalIndent(rt.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(rt.path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(rt.path, append(b, '\n'), 0o644)
+}
+
+// redactURL returns u with sensitive query parameters and secrets redacted
+// and the query sorted, so requests match however their query was built.
+func (rt *Transport) redactURL(u *url.URL) string {
+	c := *u
+	q := c.Query()
+	for k := range q {
+		if slices.Contains(SensitiveParams, strings.ToLower(k)) {
+			q[k] = []string{Redacted}
+		}
+	}
+	c.RawQuery = q.Encode()
+
+	return rt.redact(c.String())
+}
+
+// redact replaces every secret in s, as is or URL escaped.
+func (rt *Transport) redact(s string) string {
+	for _, secret := range rt.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+		s = strings.ReplaceAll(s, url.QueryEscape(secret), Redacted)
+	}
+
+	return s
+}
@@ -0,0 +1,76 @@
+package replay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cret"})
+		switch r.URL.Path {
+		case "/weather":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"temp": 47.3, "echo": "hunter2"}`)
+		case "/dump.gz":
+			w.Write([]byte{0x1f, 0x8b, 0xff, 0x00})
+		}
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	rec := &Transport{path: path, recording: true, base: http.DefaultTransport, secrets: []string{"hunter2"}}
+	c := &http.Client{Transport: rec}
+
+	get := func(c *http.Client, u string) string {
+		t.Helper()
+
+		resp, err := c.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	if got := get(c, srv.URL+"/weather?q=Seattle&appid=abc123&token=hunter2"); !strings.Contains(got, "hunter2") {
+		t.Errorf("expected the live response to be returned as is, got %s", got)
+	}
+	get(c, srv.URL+"/dump.gz")
+	if err := rec.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"abc123", "hunter2", "s3cret"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("fixture contains %q:\n%s", secret, b)
+		}
+	}
+
+	c = &http.Client{Transport: New(t, path)}
+	if got := get(c, srv.URL+"/weather?appid=other&q=Seattle&token=x"); got != `{"temp": 47.3, "echo": "REDACTED"}` {
+		t.Errorf("replayed body = %s", got)
+	}
+	if got := get(c, srv.URL+"/dump.gz"); got != "\x1f\x8b\xff\x00" {
+		t.Errorf("replayed binary body = %q", got)
+	}
+
+	// Each interaction is replayed once.
+	if _, err := c.Get(srv.URL + "/dump.gz"); err == nil || !strings.Contains(err.Error(), "no response recorded") {
+		t.Errorf("expected a request with nothing left to replay to fail, got %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+// Package retry classifies errors and retries work that failed for reasons
+// that might go away.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Class is the kind of failure an error represents.
+type Class int
+
+const (
+	// ClassRetryable errors are transient, like a 503 or a dropped
+	// connection. Errors that have not been classified are treated as
+	// retryable.
+	ClassRetryable Class = iota
+
+	// ClassPermanent errors will fail the same way every time, like an
+	// unknown job or a bad argument.
+	ClassPermanent
+
+	// ClassRateLimited errors mean an upstream rate limit was hit. They can be
+	// retried once the limit resets.
+	ClassRateLimited
+)
+
+func (c Class) String() string {
+	switch c {
+	case ClassRetryable:
+		return "retryable"
+	case ClassPermanent:
+		return "permanent"
+	case ClassRateLimited:
+		return "rate-limited"
+	default:
+		return fmt.Sprintf("Class(%d)", int(c))
+	}
+}
+
+// Error is an error with a Class attached.
+type Error struct {
+	Err   error
+	Class Class
+
+	// Reset is when a rate limit resets. Only set for rate limit errors.
+	Reset time.Time
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{Err: err, Class: ClassPermanent}
+}
+
+// Transient marks err as worth retrying.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{Err: err, Class: ClassRetryable}
+}
+
+// RateLimited marks err as a rate limit that resets at reset.
+func RateLimited(err error, reset time.Time) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{Err: err, Class: ClassRateLimited, Reset: reset}
+}
+
+// FromStatus classifies err by the HTTP status code that caused it. 429 is
+// rate limited, 408 and 5xx are retryable, and other 4xx are permanent.
+func FromStatus(code int, err error) error {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return RateLimited(err, time.Time{})
+	case code == http.StatusRequestTimeout, code >= 500:
+		return Transient(err)
+	case code >= 400:
+		return Permanent(err)
+	default:
+		return err
+	}
+}
+
+// ClassOf returns the class of err. Context cancellation is permanent, since
+// there is no point retrying once the caller has given up.
+func ClassOf(err error) Class {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Class
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ClassPermanent
+	}
+
+	return ClassRetryable
+}
+
+// ResetOf returns when the rate limit in err resets, or the zero time.
+func ResetOf(err error) time.Time {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Reset
+	}
+
+	return time.Time{}
+}
+
+// Policy decides how many times, and how often, work is attempted.
+type Policy struct {
+	// MaxAttempts is the total number of tries, including the first.
+	MaxAttempts int
+
+	// Initial is the wait before the first retry.
+	Initial time.Duration
+
+	// Max caps the wait between tries, including waiting for a rate limit to
+	// reset. A rate limit that resets after Max is not waited for.
+	Max time.Duration
+
+	// Multiplier grows the wait after each try.
+	Multiplier float64
+
+	// Jitter is the fraction, from 0 to 1, of each wait that is randomized.
+	Jitter float64
+}
+
+var (
+	// Default is what jobs use unless they declare their own policy.
+	Default = Policy{
+		MaxAttempts: 3,
+		Initial:     time.Second,
+		Max:         time.Minute,
+		Multiplier:  2,
+		Jitter:      0.2,
+	}
+
+	// Never tries once.
+	Never = Policy{MaxAttempts: 1}
+)
+
+// Backoff is how long to wait after the given attempt, counting from 1,
+// before trying again.
+func (p Policy) Backoff(attempt int) time.Duration {
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+
+	d := float64(p.Initial) * math.Pow(mult, float64(attempt-1))
+	if p.Max > 0 && d > float64(p.Max) {
+		d = float64(p.Max)
+	}
+
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(d)
+}
+
+// Do calls fn until it succeeds, returns a permanent error, hits a rate
+// limit that won't reset soon enough, or the policy runs out of attempts.
+// The last error is returned with its class intact.
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context, attempt int) error) error {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx, attempt)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= attempts {
+			return err
+		}
+
+		wait := p.Backoff(attempt)
+		switch ClassOf(err) {
+		case ClassPermanent:
+			return err
+		case ClassRateLimited:
+			if reset := ResetOf(err); !reset.IsZero() {
+				wait = time.Until(reset)
+			}
+			if p.Max > 0 && wait > p.Max {
+				return err
+			}
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClassOf(t *testing.T) {
+	base := errors.New("boom")
+	tests := []struct {
+		err  error
+		want Class
+	}{
+		{base, ClassRetryable},
+		{Permanent(base), ClassPermanent},
+		{fmt.Errorf("wrapped: %w", Permanent(base)), ClassPermanent},
+		{RateLimited(base, time.Now()), ClassRateLimited},
+		{FromStatus(http.StatusServiceUnavailable, base), ClassRetryable},
+		{FromStatus(http.StatusNotFound, base), ClassPermanent},
+		{FromStatus(http.StatusTooManyRequests, base), ClassRateLimited},
+		{context.Canceled, ClassPermanent},
+	}
+
+	for _, tc := range tests {
+		if got := ClassOf(tc.err); got != tc.want {
+			t.Errorf("ClassOf(%v) = %s, want %s", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := Policy{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second} {
+		if got := p.Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.Backoff(1); got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Fatalf("Backoff(1) with jitter = %s, want within 50%% of 1s", got)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	ctx := context.Background()
+	p := Policy{MaxAttempts: 3, Initial: time.Millisecond, Max: 10 * time.Millisecond}
+
+	calls := 0
+	err := Do(ctx, p, func(context.Context, int) error {
+		calls++
+		if calls < 3 {
+			return errors.New("flaky")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("expected success on the third call, got %d calls and %+v", calls, err)
+	}
+
+	calls = 0
+	err = Do(ctx, p, func(context.Context, int) error {
+		calls++
+		return Permanent(errors.New("nope"))
+	})
+	if ClassOf(err) != ClassPermanent || calls != 1 {
+		t.Errorf("expected one call for a permanent error, got %d calls and %+v", calls, err)
+	}
+
+	calls = 0
+	err = Do(ctx, p, func(context.Context, int) error {
+		calls++
+		return RateLimited(errors.New("slow down"), time.Now().Add(time.Hour))
+	})
+	if ClassOf(err) != ClassRateLimited || calls != 1 {
+		t.Errorf("expected to give up on a far off rate limit reset, got %d calls and %+v", calls, err)
+	}
+}
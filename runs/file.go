@@ -0,0 +1,103 @@
+package runs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+
+	"github.com/icco/cron/journal"
+)
+
+// File is a Store backed by a journal on local disk. Runs are served from
+// memory and every save is appended to the journal, so history survives a
+// restart. Each run is written at least twice, so the journal is compacted
+// when it is opened and once enough of it is superseded.
+type File struct {
+	*Memory
+
+	mu      sync.Mutex
+	journal *journal.Journal
+}
+
+// OpenFile opens or creates a file store at path.
+func OpenFile(path string) (*File, error) {
+	j, err := journal.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{Memory: m, journal: j}
+	if err := f.compact(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// compact rewrites the journal with just the runs kept in memory, oldest
+// first. Callers must hold mu, or own f.
+func (f *File) compact() error {
+	all := f.Memory.all()
+	records := make([]any, len(all))
+	for i, r := range all {
+		records[len(all)-1-i] = r
+	}
+
+	return f.journal.Rewrite(records)
+}
+
+// ReadFile loads the runs in the file stores at paths into memory, without
+// compacting or writing to them. A missing file has no runs.
+func ReadFile(paths ...string) (*Memory, error) {
+	m := NewMemory()
+	for _, path := range paths {
+		err := journal.Read(path, func(b json.RawMessage) error {
+			var r Run
+			if err := json.Unmarshal(b, &r); err != nil {
+				return fmt.Errorf("decode run: %w", err)
+			}
+			m.runs[r.ID] = &r
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	m.trim()
+
+	return m, nil
+}
+
+// Save implements Store.
+func (f *File) Save(ctx context.Context, r *Run) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.journal.Append(r); err != nil {
+		return fmt.Errorf("append run: %w", err)
+	}
+	if err := f.Memory.Save(ctx, r); err != nil {
+		return err
+	}
+
+	if f.journal.NeedsCompaction(f.Memory.Len()) {
+		if err := f.compact(); err != nil {
+			return fmt.Errorf("compact runs: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the underlying journal.
+func (f *File) Close() error {
+	return f.journal.Close()
+}
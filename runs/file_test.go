@@ -0,0 +1,159 @@
+package runs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/icco/cron/journal"
+)
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "runs.jsonl")
+
+	s, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, r := range []*Run{
+		{ID: "a", Job: "code", Status: Running, Started: start},
+		{ID: "b", Job: "stats", Status: Failed, Error: "boom", Started: start.Add(time.Minute)},
+		{ID: "c", Job: "code", Status: Succeeded, Started: start.Add(2 * time.Minute)},
+	} {
+		if err := s.Save(ctx, r); err != nil {
+			t.Fatalf("save %d: %+v", i, err)
+		}
+	}
+	if err := s.Save(ctx, &Run{ID: "a", Job: "code", Status: Succeeded, Started: start, Finished: start.Add(time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	a, err := s.Get(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Status != Succeeded || a.Duration() != time.Second {
+		t.Errorf("expected the last save of a to win, got %+v", a)
+	}
+
+	if _, err := s.Get(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %+v", err)
+	}
+
+	code, err := s.List(ctx, Filter{Job: "code"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 2 || code[0].ID != "c" || code[1].ID != "a" {
+		t.Errorf("expected code runs c then a, got %+v", code)
+	}
+
+	failed, err := s.List(ctx, Filter{Status: Failed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].ID != "b" {
+		t.Errorf("expected only b to have failed, got %+v", failed)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "runs.jsonl")
+
+	m, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected a missing file to have no runs, got %+v", err)
+	}
+	if list, _ := m.List(ctx, Filter{}); len(list) != 0 {
+		t.Errorf("expected no runs, got %+v", list)
+	}
+
+	s, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.Save(ctx, &Run{ID: "a", Job: "code", Status: Running, Started: start})
+	s.Save(ctx, &Run{ID: "a", Job: "code", Status: Succeeded, Started: start, Finished: start.Add(time.Second)})
+
+	m, err = ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, err := m.Get(ctx, "a"); err != nil || a.Status != Succeeded {
+		t.Errorf("expected the last save of a, got %+v, %v", a, err)
+	}
+
+	// The writer's journal must be untouched, so its later saves still land.
+	s.Save(ctx, &Run{ID: "b", Job: "stats", Status: Succeeded, Started: start.Add(time.Minute)})
+	m, err = ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get(ctx, "b"); err != nil {
+		t.Errorf("expected b to be read after it was saved, got %v", err)
+	}
+
+	otherPath := filepath.Join(t.TempDir(), "other.jsonl")
+	other, err := OpenFile(otherPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	other.Save(ctx, &Run{ID: "c", Job: "minute", Status: Failed, Started: start.Add(2 * time.Minute)})
+	m, err = ReadFile(path, otherPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := m.List(ctx, Filter{}); len(list) != 3 || list[0].ID != "c" {
+		t.Errorf("expected the runs of both files, newest first, got %+v", list)
+	}
+}
+
+func TestFileCompacts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "runs.jsonl")
+
+	s, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 3 * journal.MinStale {
+		if err := s.Save(ctx, &Run{ID: "a", Job: "code", Status: Running, Started: start, Attempts: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s.journal.Len(); n > journal.MinStale+1 {
+		t.Errorf("journal has %d records for one run, want it compacted", n)
+	}
+
+	m, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := m.Get(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Attempts != 3*journal.MinStale-1 {
+		t.Errorf("expected the last save to survive compaction, got %+v", a)
+	}
+}
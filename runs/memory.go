@@ -0,0 +1,116 @@
+package runs
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Memory is a Store that only keeps runs in memory.
+type Memory struct {
+	// Max is the number of runs to keep. Zero means 1000.
+	Max int
+
+	mu   sync.RWMutex
+	runs map[string]*Run
+}
+
+// NewMemory creates an empty in memory store.
+func NewMemory() *Memory {
+	return &Memory{runs: map[string]*Run{}}
+}
+
+// Save implements Store.
+func (m *Memory) Save(ctx context.Context, r *Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cp := *r
+	m.runs[r.ID] = &cp
+	m.trim()
+
+	return nil
+}
+
+// trim drops the oldest runs once there are more than Max.
+func (m *Memory) trim() {
+	max := m.Max
+	if max == 0 {
+		max = 1000
+	}
+	if len(m.runs) <= max {
+		return
+	}
+
+	all := m.sorted()
+	for _, r := range all[max:] {
+		delete(m.runs, r.ID)
+	}
+}
+
+// Get implements Store.
+func (m *Memory) Get(ctx context.Context, id string) (*Run, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	r, ok := m.runs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	cp := *r
+	return &cp, nil
+}
+
+// List implements Store.
+func (m *Memory) List(ctx context.Context, f Filter) ([]*Run, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	limit := f.Limit
+	if limit == 0 {
+		limit = 100
+	}
+
+	var out []*Run
+	for _, r := range m.sorted() {
+		if !f.matches(r) {
+			continue
+		}
+
+		cp := *r
+		out = append(out, &cp)
+		if len(out) == limit {
+			break
+		}
+	}
+
+	return out, nil
+}
+
+// Len is how many runs are kept.
+func (m *Memory) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.runs)
+}
+
+// all returns every run, newest first.
+func (m *Memory) all() []*Run {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.sorted()
+}
+
+// sorted returns all runs, newest first. Callers must hold mu.
+func (m *Memory) sorted() []*Run {
+	all := make([]*Run, 0, len(m.runs))
+	for _, r := range m.runs {
+		all = append(all, r)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Started.After(all[j].Started) })
+
+	return all
+}
@@ -0,0 +1,96 @@
+// Package runs records every time a job is run.
+package runs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/icco/cron/shared"
+)
+
+// Status is the outcome of a run.
+type Status string
+
+const (
+	// Running means the job has not finished yet.
+	Running Status = "running"
+
+	// Succeeded means the job returned no error.
+	Succeeded Status = "succeeded"
+
+	// Failed means the job returned an error.
+	Failed Status = "failed"
+
+	// Skipped means the job was not run, see Reason for why.
+	Skipped Status = "skipped"
+
+	// Interrupted means the job was stopped by a shutdown before it
+	// finished.
+	Interrupted Status = "interrupted"
+)
+
+// ErrNotFound is returned when a run does not exist.
+var ErrNotFound = errors.New("run not found")
+
+// Run is a single invocation of a job.
+type Run struct {
+	ID         string            `json:"id"`
+	Job        string            `json:"job"`
+	Args       map[string]string `json:"args,omitempty"`
+	MessageID  string            `json:"message_id,omitempty"`
+	Status     Status            `json:"status"`
+	Attempts   int               `json:"attempts,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	ErrorClass string            `json:"error_class,omitempty"`
+	Reason     string            `json:"reason,omitempty"`
+	Started    time.Time         `json:"started"`
+	Finished   time.Time         `json:"finished,omitzero"`
+
+	// DryRun is true if writes were recorded in Writes instead of made.
+	DryRun bool            `json:"dry_run,omitempty"`
+	Writes *shared.Summary `json:"writes,omitempty"`
+}
+
+// Duration is how long the run took. It is zero until the run finishes, as
+// Started and Finished come from the clock of whatever ran it.
+func (r *Run) Duration() time.Duration {
+	if r.Finished.IsZero() {
+		return 0
+	}
+
+	return r.Finished.Sub(r.Started)
+}
+
+// Filter narrows the runs returned by List.
+type Filter struct {
+	Job    string
+	Status Status
+
+	// Limit is the maximum number of runs to return. Zero means 100.
+	Limit int
+}
+
+func (f Filter) matches(r *Run) bool {
+	if f.Job != "" && r.Job != f.Job {
+		return false
+	}
+
+	if f.Status != "" && r.Status != f.Status {
+		return false
+	}
+
+	return true
+}
+
+// Store persists runs.
+type Store interface {
+	// Save creates or updates a run.
+	Save(ctx context.Context, r *Run) error
+
+	// Get returns a run by ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (*Run, error)
+
+	// List returns runs matching f, newest first.
+	List(ctx context.Context, f Filter) ([]*Run, error)
+}
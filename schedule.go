@@ -0,0 +1,49 @@
+package cron
+
+import (
+	"context"
+	"time"
+
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/scheduler"
+)
+
+// NewScheduler creates a scheduler with every registered job that declares a
+// schedule, which runs them through Act.
+func (cfg *Config) NewScheduler(loc *time.Location, jitter time.Duration) (*scheduler.Scheduler, error) {
+	s := &scheduler.Scheduler{
+		Log:      cfg.Log,
+		Location: loc,
+		Jitter:   jitter,
+		Dispatch: func(ctx context.Context, job string) error {
+			return cfg.Act(ctx, &Message{Job: job})
+		},
+	}
+
+	for _, j := range jobs.All() {
+		if j.Schedule() == "" {
+			continue
+		}
+
+		if err := s.Add(j.Name(), j.Schedule()); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// DeclaredSchedules returns the schedule of every registered job that has
+// one, for syncing to Cloud Scheduler.
+func DeclaredSchedules() []scheduler.Declared {
+	var all []scheduler.Declared
+	for _, j := range jobs.All() {
+		if j.Schedule() == "" {
+			continue
+		}
+
+		all = append(all, scheduler.Declared{Job: j.Name(), Description: j.Description(), Spec: j.Schedule()})
+	}
+
+	return all
+}
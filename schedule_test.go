@@ -0,0 +1,28 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewScheduler(t *testing.T) {
+	cfg := &Config{}
+	s, err := cfg.NewScheduler(time.UTC, 0)
+	if err != nil {
+		t.Fatalf("expected every registered schedule to parse, got %+v", err)
+	}
+
+	found := map[string]bool{}
+	for _, e := range s.Entries() {
+		found[e.Job] = true
+	}
+
+	for _, job := range []string{"minute", "pinboard", "goodreads", "user-tweets"} {
+		if !found[job] {
+			t.Errorf("expected %q to be scheduled", job)
+		}
+	}
+	if found["spider"] {
+		t.Error("expected spider to stay unscheduled")
+	}
+}
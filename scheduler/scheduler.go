@@ -0,0 +1,200 @@
+// Package scheduler runs jobs on cron schedules inside the process, as an
+// alternative to Cloud Scheduler publishing to the cron topic.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	robfig "github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// DispatchFunc starts a job by name.
+type DispatchFunc func(ctx context.Context, job string) error
+
+// Entry is a job on a schedule.
+type Entry struct {
+	Job  string    `json:"job"`
+	Spec string    `json:"spec"`
+	Next time.Time `json:"next"`
+	Prev time.Time `json:"prev,omitzero"`
+
+	schedule robfig.Schedule
+}
+
+// Scheduler dispatches jobs when their schedules say so.
+type Scheduler struct {
+	Log *zap.SugaredLogger
+
+	// Location is the timezone for schedules that don't set CRON_TZ.
+	// Defaults to UTC.
+	Location *time.Location
+
+	// Jitter is the most a dispatch is randomly delayed by, so jobs on the
+	// same schedule don't all start at once.
+	Jitter time.Duration
+
+	Dispatch DispatchFunc
+
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries []*Entry
+	wake    chan struct{}
+}
+
+// Parse parses a standard five field cron expression, or a descriptor like
+// "@hourly". Specs may start with CRON_TZ=Area/City to set their timezone,
+// otherwise loc is used.
+func Parse(spec string, loc *time.Location) (robfig.Schedule, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	if !strings.HasPrefix(spec, "CRON_TZ=") && !strings.HasPrefix(spec, "TZ=") {
+		spec = fmt.Sprintf("CRON_TZ=%s %s", loc, spec)
+	}
+
+	s, err := robfig.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("parse schedule %q: %w", spec, err)
+	}
+
+	return s, nil
+}
+
+func (s *Scheduler) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+
+	return time.Now()
+}
+
+// Add puts job on the schedule described by spec.
+func (s *Scheduler) Add(job, spec string) error {
+	sched, err := Parse(spec, s.Location)
+	if err != nil {
+		return fmt.Errorf("schedule %q: %w", job, err)
+	}
+
+	s.add(job, spec, sched)
+	return nil
+}
+
+func (s *Scheduler) add(job, spec string, sched robfig.Schedule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, &Entry{
+		Job:      job,
+		Spec:     spec,
+		Next:     sched.Next(s.clock()),
+		schedule: sched,
+	})
+
+	if s.wake != nil {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Entries returns every scheduled job, soonest first.
+func (s *Scheduler) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Entry, len(s.entries))
+	for i, e := range s.entries {
+		out[i] = *e
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Next.Before(out[j].Next) })
+
+	return out
+}
+
+// Run dispatches jobs as they come due until ctx is done. Dispatches happen in
+// their own goroutines, so a slow job never delays the schedule.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	s.wake = make(chan struct{}, 1)
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		s.mu.Lock()
+		var next time.Time
+		for _, e := range s.entries {
+			if next.IsZero() || e.Next.Before(next) {
+				next = e.Next
+			}
+		}
+		s.mu.Unlock()
+
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = next.Sub(s.clock())
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-s.wake:
+			t.Stop()
+			continue
+		case <-t.C:
+		}
+
+		now := s.clock()
+		s.mu.Lock()
+		var due []string
+		for _, e := range s.entries {
+			if !e.Next.After(now) {
+				due = append(due, e.Job)
+				e.Prev = e.Next
+				e.Next = e.schedule.Next(now)
+			}
+		}
+		s.mu.Unlock()
+
+		for _, job := range due {
+			wg.Add(1)
+			go func(job string) {
+				defer wg.Done()
+				s.dispatch(ctx, job)
+			}(job)
+		}
+	}
+}
+
+func (s *Scheduler) dispatch(ctx context.Context, job string) {
+	if s.Jitter > 0 {
+		t := time.NewTimer(time.Duration(rand.Int63n(int64(s.Jitter))))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+
+	if s.Log != nil {
+		s.Log.Infow("dispatching scheduled job", "job", job)
+	}
+
+	if err := s.Dispatch(ctx, job); err != nil && s.Log != nil {
+		s.Log.Errorw("scheduled job failed", "job", job, zap.Error(err))
+	}
+}
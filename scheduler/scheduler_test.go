@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no tzdata: %+v", err)
+	}
+
+	from := time.Date(2024, 3, 10, 5, 0, 0, 0, time.UTC) // Midnight in New York.
+	tests := []struct {
+		spec string
+		loc  *time.Location
+		want time.Time
+	}{
+		{"0 * * * *", nil, time.Date(2024, 3, 10, 6, 0, 0, 0, time.UTC)},
+		{"*/30 * * * *", nil, time.Date(2024, 3, 10, 5, 30, 0, 0, time.UTC)},
+		// DST starts at 2am on March 10th, so 3am New York is 7am UTC.
+		{"0 3 * * *", ny, time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC)},
+		{"CRON_TZ=Europe/London 0 9 * * *", ny, time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range tests {
+		s, err := Parse(tc.spec, tc.loc)
+		if err != nil {
+			t.Errorf("Parse(%q): %+v", tc.spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tc.want) {
+			t.Errorf("Parse(%q).Next(%s) = %s, want %s", tc.spec, from, got.UTC(), tc.want)
+		}
+	}
+
+	if _, err := Parse("not a schedule", nil); err == nil {
+		t.Error("expected a bad spec to fail")
+	}
+}
+
+func TestRun(t *testing.T) {
+	var mu sync.Mutex
+	got := map[string]int{}
+
+	s := &Scheduler{
+		Dispatch: func(ctx context.Context, job string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got[job]++
+			return nil
+		},
+	}
+	// Real schedules are at least a second apart, so use a faster one.
+	s.add("fast", "every 20ms", every(20*time.Millisecond))
+	if err := s.Add("slow", "@hourly"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := s.Entries()
+	if len(entries) != 2 || entries[0].Job != "fast" {
+		t.Fatalf("expected fast to be next, got %+v", entries)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 110*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got["fast"] < 2 || got["slow"] != 0 {
+		t.Errorf("expected fast to run a few times and slow not at all, got %+v", got)
+	}
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
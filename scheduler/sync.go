@@ -0,0 +1,341 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	cloudscheduler "cloud.google.com/go/scheduler/apiv1"
+	"cloud.google.com/go/scheduler/apiv1/schedulerpb"
+	"go.uber.org/zap"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// ManagedPrefix starts the ID of every Cloud Scheduler job Sync owns. Jobs
+// without it are never touched.
+const ManagedPrefix = "cron-"
+
+// Client is the part of the Cloud Scheduler API that Sync uses.
+type Client interface {
+	ListJobs(ctx context.Context, parent string) ([]*schedulerpb.Job, error)
+	CreateJob(ctx context.Context, parent string, job *schedulerpb.Job) error
+	UpdateJob(ctx context.Context, job *schedulerpb.Job) error
+	DeleteJob(ctx context.Context, name string) error
+}
+
+// Cloud is a Client backed by GCP Cloud Scheduler.
+type Cloud struct {
+	client *cloudscheduler.CloudSchedulerClient
+}
+
+// NewCloud creates a Cloud Scheduler client. Options are passed to the
+// underlying client.
+func NewCloud(ctx context.Context, opts ...option.ClientOption) (*Cloud, error) {
+	c, err := cloudscheduler.NewCloudSchedulerClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create cloud scheduler client: %w", err)
+	}
+
+	return &Cloud{client: c}, nil
+}
+
+// ListJobs implements Client.
+func (c *Cloud) ListJobs(ctx context.Context, parent string) ([]*schedulerpb.Job, error) {
+	var all []*schedulerpb.Job
+	it := c.client.ListJobs(ctx, &schedulerpb.ListJobsRequest{Parent: parent})
+	for {
+		j, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list jobs in %q: %w", parent, err)
+		}
+		all = append(all, j)
+	}
+
+	return all, nil
+}
+
+// CreateJob implements Client.
+func (c *Cloud) CreateJob(ctx context.Context, parent string, job *schedulerpb.Job) error {
+	if _, err := c.client.CreateJob(ctx, &schedulerpb.CreateJobRequest{Parent: parent, Job: job}); err != nil {
+		return fmt.Errorf("create job %q: %w", job.GetName(), err)
+	}
+
+	return nil
+}
+
+// UpdateJob implements Client.
+func (c *Cloud) UpdateJob(ctx context.Context, job *schedulerpb.Job) error {
+	if _, err := c.client.UpdateJob(ctx, &schedulerpb.UpdateJobRequest{Job: job}); err != nil {
+		return fmt.Errorf("update job %q: %w", job.GetName(), err)
+	}
+
+	return nil
+}
+
+// DeleteJob implements Client.
+func (c *Cloud) DeleteJob(ctx context.Context, name string) error {
+	if err := c.client.DeleteJob(ctx, &schedulerpb.DeleteJobRequest{Name: name}); err != nil {
+		return fmt.Errorf("delete job %q: %w", name, err)
+	}
+
+	return nil
+}
+
+// Close closes the underlying client.
+func (c *Cloud) Close() error {
+	return c.client.Close()
+}
+
+// Declared is a job's schedule as declared in code.
+type Declared struct {
+	Job         string
+	Description string
+	Spec        string
+}
+
+// Action is what a Change does to a Cloud Scheduler job.
+type Action string
+
+// Actions a Change can take.
+const (
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+
+	// Conflict is a job that isn't managed but runs a declared job, which
+	// would run twice if its managed job were synced too. Apply leaves both
+	// alone until it is deleted.
+	Conflict Action = "conflict"
+)
+
+// Change is one difference between the declared schedules and Cloud
+// Scheduler.
+type Change struct {
+	Action Action
+	Job    *schedulerpb.Job
+
+	// Diff lists the fields an update changes, as "field: old -> new".
+	Diff []string
+
+	// Managed is the name of the job a conflict keeps from being synced.
+	Managed string
+}
+
+func (c Change) String() string {
+	s := fmt.Sprintf("%s %s", c.Action, c.Job.GetName())
+	switch c.Action {
+	case Create:
+		s += fmt.Sprintf(" (%q %s)", c.Job.GetSchedule(), c.Job.GetTimeZone())
+	case Update:
+		s += " (" + strings.Join(c.Diff, ", ") + ")"
+	case Conflict:
+		s += fmt.Sprintf(" (publishes %s; delete it to sync %s)", c.Job.GetPubsubTarget().GetData(), c.Managed)
+	}
+
+	return s
+}
+
+// Plan is the changes needed to make Cloud Scheduler match the declared
+// schedules.
+type Plan []Change
+
+func (p Plan) String() string {
+	if len(p) == 0 {
+		return "no changes\n"
+	}
+
+	var b strings.Builder
+	for _, c := range p {
+		fmt.Fprintln(&b, c)
+	}
+
+	return b.String()
+}
+
+// Sync makes Cloud Scheduler publish a message to Topic for every declared
+// schedule.
+type Sync struct {
+	Log    *zap.SugaredLogger
+	Client Client
+
+	// Project and Region are where the Cloud Scheduler jobs live.
+	Project string
+	Region  string
+
+	// Topic is the short name of the Pub/Sub topic jobs publish to.
+	Topic string
+
+	// TimeZone is used for schedules that don't set CRON_TZ or TZ. Defaults
+	// to UTC.
+	TimeZone string
+}
+
+func (s *Sync) parent() string {
+	return fmt.Sprintf("projects/%s/locations/%s", s.Project, s.Region)
+}
+
+// jobName is the Cloud Scheduler name of the job that triggers job.
+func (s *Sync) jobName(job string) string {
+	return fmt.Sprintf("%s/jobs/%s%s", s.parent(), ManagedPrefix, job)
+}
+
+// want builds the Cloud Scheduler job for d.
+func (s *Sync) want(d Declared) (*schedulerpb.Job, error) {
+	if _, err := Parse(d.Spec, nil); err != nil {
+		return nil, fmt.Errorf("job %q: %w", d.Job, err)
+	}
+
+	tz := s.TimeZone
+	if tz == "" {
+		tz = "UTC"
+	}
+	spec := d.Spec
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if rest, ok := strings.CutPrefix(spec, prefix); ok {
+			tz, spec, _ = strings.Cut(rest, " ")
+			break
+		}
+	}
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@") {
+		return nil, fmt.Errorf("job %q: cloud scheduler does not support %q", d.Job, spec)
+	}
+
+	data, err := json.Marshal(map[string]string{"job": d.Job})
+	if err != nil {
+		return nil, err
+	}
+
+	return &schedulerpb.Job{
+		Name:        s.jobName(d.Job),
+		Description: d.Description,
+		Schedule:    spec,
+		TimeZone:    tz,
+		Target: &schedulerpb.Job_PubsubTarget{
+			PubsubTarget: &schedulerpb.PubsubTarget{
+				TopicName: fmt.Sprintf("projects/%s/topics/%s", s.Project, s.Topic),
+				Data:      data,
+			},
+		},
+	}, nil
+}
+
+// Plan compares declared schedules with the jobs in Cloud Scheduler. Managed
+// jobs that are no longer declared are deleted. Unmanaged jobs that publish a
+// declared job to Topic are conflicts, and that job isn't synced.
+func (s *Sync) Plan(ctx context.Context, declared []Declared) (Plan, error) {
+	existing, err := s.Client.ListJobs(ctx, s.parent())
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := s.parent() + "/jobs/" + ManagedPrefix
+	topic := fmt.Sprintf("projects/%s/topics/%s", s.Project, s.Topic)
+	have := map[string]*schedulerpb.Job{}
+	unmanaged := map[string][]*schedulerpb.Job{}
+	for _, j := range existing {
+		have[j.GetName()] = j
+		if strings.HasPrefix(j.GetName(), prefix) || j.GetPubsubTarget().GetTopicName() != topic {
+			continue
+		}
+
+		var data struct {
+			Job string `json:"job"`
+		}
+		if err := json.Unmarshal(j.GetPubsubTarget().GetData(), &data); err == nil && data.Job != "" {
+			unmanaged[data.Job] = append(unmanaged[data.Job], j)
+		}
+	}
+
+	var plan Plan
+	seen := map[string]bool{}
+	for _, d := range declared {
+		w, err := s.want(d)
+		if err != nil {
+			return nil, err
+		}
+		seen[w.Name] = true
+
+		if js := unmanaged[d.Job]; len(js) > 0 {
+			for _, j := range js {
+				plan = append(plan, Change{Action: Conflict, Job: j, Managed: w.Name})
+			}
+			continue
+		}
+
+		h, ok := have[w.Name]
+		if !ok {
+			plan = append(plan, Change{Action: Create, Job: w})
+			continue
+		}
+
+		if diff := diffJobs(h, w); len(diff) > 0 {
+			plan = append(plan, Change{Action: Update, Job: w, Diff: diff})
+		}
+	}
+
+	for _, j := range existing {
+		if strings.HasPrefix(j.GetName(), prefix) && !seen[j.GetName()] {
+			plan = append(plan, Change{Action: Delete, Job: j})
+		}
+	}
+
+	sort.SliceStable(plan, func(i, k int) bool { return plan[i].Job.GetName() < plan[k].Job.GetName() })
+
+	return plan, nil
+}
+
+// Apply makes the changes in plan, stopping at the first error. Conflicts
+// are logged and skipped.
+func (s *Sync) Apply(ctx context.Context, plan Plan) error {
+	for _, c := range plan {
+		var err error
+		switch c.Action {
+		case Conflict:
+			if s.Log != nil {
+				s.Log.Warnw("not syncing scheduler job, an unmanaged job already runs it", "job", c.Managed, "unmanaged", c.Job.GetName())
+			}
+			continue
+		case Create:
+			err = s.Client.CreateJob(ctx, s.parent(), c.Job)
+		case Update:
+			err = s.Client.UpdateJob(ctx, c.Job)
+		case Delete:
+			err = s.Client.DeleteJob(ctx, c.Job.GetName())
+		default:
+			err = fmt.Errorf("unknown action %q", c.Action)
+		}
+		if err != nil {
+			return err
+		}
+
+		if s.Log != nil {
+			s.Log.Infow("synced scheduler job", "action", c.Action, "job", c.Job.GetName())
+		}
+	}
+
+	return nil
+}
+
+func diffJobs(have, want *schedulerpb.Job) []string {
+	var diff []string
+	field := func(name, old, new string) {
+		if old != new {
+			diff = append(diff, fmt.Sprintf("%s: %q -> %q", name, old, new))
+		}
+	}
+
+	field("description", have.GetDescription(), want.GetDescription())
+	field("schedule", have.GetSchedule(), want.GetSchedule())
+	field("time_zone", have.GetTimeZone(), want.GetTimeZone())
+	field("topic", have.GetPubsubTarget().GetTopicName(), want.GetPubsubTarget().GetTopicName())
+	field("data", string(have.GetPubsubTarget().GetData()), string(want.GetPubsubTarget().GetData()))
+
+	return diff
+}
@@ -0,0 +1,184 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/scheduler/apiv1/schedulerpb"
+	"google.golang.org/protobuf/proto"
+)
+
+type fakeClient struct {
+	jobs map[string]*schedulerpb.Job
+}
+
+func (f *fakeClient) ListJobs(ctx context.Context, parent string) ([]*schedulerpb.Job, error) {
+	var all []*schedulerpb.Job
+	for name, j := range f.jobs {
+		if strings.HasPrefix(name, parent+"/jobs/") {
+			all = append(all, proto.Clone(j).(*schedulerpb.Job))
+		}
+	}
+	return all, nil
+}
+
+func (f *fakeClient) CreateJob(ctx context.Context, parent string, job *schedulerpb.Job) error {
+	f.jobs[job.GetName()] = job
+	return nil
+}
+
+func (f *fakeClient) UpdateJob(ctx context.Context, job *schedulerpb.Job) error {
+	f.jobs[job.GetName()] = job
+	return nil
+}
+
+func (f *fakeClient) DeleteJob(ctx context.Context, name string) error {
+	delete(f.jobs, name)
+	return nil
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	parent := "projects/p/locations/us-central1/jobs/"
+	fake := &fakeClient{jobs: map[string]*schedulerpb.Job{
+		// Managed and still declared, but on an old schedule.
+		parent + "cron-stats": {
+			Name:     parent + "cron-stats",
+			Schedule: "0 * * * *",
+			TimeZone: "UTC",
+			Target: &schedulerpb.Job_PubsubTarget{PubsubTarget: &schedulerpb.PubsubTarget{
+				TopicName: "projects/p/topics/cron",
+				Data:      []byte(`{"job":"stats"}`),
+			}},
+		},
+		// Managed but no longer declared.
+		parent + "cron-gone": {Name: parent + "cron-gone", Schedule: "0 * * * *"},
+		// Not managed, so left alone.
+		parent + "backup": {Name: parent + "backup", Schedule: "0 0 * * *"},
+	}}
+
+	s := &Sync{Client: fake, Project: "p", Region: "us-central1", Topic: "cron"}
+	declared := []Declared{
+		{Job: "stats", Spec: "*/5 * * * *"},
+		{Job: "goodreads", Description: "Syncs books.", Spec: "CRON_TZ=America/New_York 0 * * * *"},
+	}
+
+	plan, err := s.Plan(ctx, declared)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := plan.String()
+	want := []string{
+		`delete projects/p/locations/us-central1/jobs/cron-gone`,
+		`create projects/p/locations/us-central1/jobs/cron-goodreads ("0 * * * *" America/New_York)`,
+		`update projects/p/locations/us-central1/jobs/cron-stats (schedule: "0 * * * *" -> "*/5 * * * *")`,
+	}
+	if got != strings.Join(want, "\n")+"\n" {
+		t.Errorf("plan:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	if len(fake.jobs) != 3 {
+		t.Fatalf("planning changed %d jobs", len(fake.jobs))
+	}
+
+	if err := s.Apply(ctx, plan); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := fake.jobs[parent+"cron-gone"]; ok {
+		t.Error("expected cron-gone to be deleted")
+	}
+	if _, ok := fake.jobs[parent+"backup"]; !ok {
+		t.Error("expected unmanaged job to be kept")
+	}
+	gr := fake.jobs[parent+"cron-goodreads"]
+	if gr == nil || string(gr.GetPubsubTarget().GetData()) != `{"job":"goodreads"}` {
+		t.Errorf("goodreads job = %v", gr)
+	}
+
+	plan, err = s.Plan(ctx, declared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 0 {
+		t.Errorf("expected no changes after apply, got:\n%s", plan)
+	}
+}
+
+func TestSyncBadSpec(t *testing.T) {
+	s := &Sync{Client: &fakeClient{jobs: map[string]*schedulerpb.Job{}}, Project: "p", Region: "r", Topic: "cron"}
+
+	for _, spec := range []string{"nope", "@hourly"} {
+		if _, err := s.Plan(context.Background(), []Declared{{Job: "x", Spec: spec}}); err == nil {
+			t.Errorf("expected %q to fail", spec)
+		}
+	}
+}
+
+func TestSyncTimeZone(t *testing.T) {
+	s := &Sync{Client: &fakeClient{jobs: map[string]*schedulerpb.Job{}}, Project: "p", Region: "r", Topic: "cron", TimeZone: "UTC"}
+
+	for spec, want := range map[string]string{
+		"30 6 * * *":                          `("30 6 * * *" UTC)`,
+		"CRON_TZ=America/New_York 30 6 * * *": `("30 6 * * *" America/New_York)`,
+		"TZ=Europe/London 30 6 * * *":         `("30 6 * * *" Europe/London)`,
+	} {
+		plan, err := s.Plan(context.Background(), []Declared{{Job: "x", Spec: spec}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := plan.String(); got != "create projects/p/locations/r/jobs/cron-x "+want+"\n" {
+			t.Errorf("%q planned %s", spec, got)
+		}
+	}
+}
+
+func TestSyncConflict(t *testing.T) {
+	ctx := context.Background()
+	parent := "projects/p/locations/r/jobs/"
+	hourly := &schedulerpb.Job{
+		Name:     parent + "pinboard-hourly",
+		Schedule: "0 * * * *",
+		Target: &schedulerpb.Job_PubsubTarget{PubsubTarget: &schedulerpb.PubsubTarget{
+			TopicName: "projects/p/topics/cron",
+			Data:      []byte(`{"job":"pinboard"}`),
+		}},
+	}
+	other := &schedulerpb.Job{
+		Name: parent + "other-topic",
+		Target: &schedulerpb.Job_PubsubTarget{PubsubTarget: &schedulerpb.PubsubTarget{
+			TopicName: "projects/p/topics/elsewhere",
+			Data:      []byte(`{"job":"stats"}`),
+		}},
+	}
+	fake := &fakeClient{jobs: map[string]*schedulerpb.Job{hourly.Name: hourly, other.Name: other}}
+
+	s := &Sync{Client: fake, Project: "p", Region: "r", Topic: "cron"}
+	plan, err := s.Plan(ctx, []Declared{
+		{Job: "pinboard", Spec: "*/15 * * * *"},
+		{Job: "stats", Spec: "*/5 * * * *"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`create projects/p/locations/r/jobs/cron-stats ("*/5 * * * *" UTC)`,
+		`conflict projects/p/locations/r/jobs/pinboard-hourly (publishes {"job":"pinboard"}; delete it to sync projects/p/locations/r/jobs/cron-pinboard)`,
+	}
+	if got := plan.String(); got != strings.Join(want, "\n")+"\n" {
+		t.Errorf("plan:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	if err := s.Apply(ctx, plan); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.jobs[parent+"cron-pinboard"]; ok {
+		t.Error("expected a conflicting job not to be synced")
+	}
+	if _, ok := fake.jobs[hourly.Name]; !ok {
+		t.Error("expected the unmanaged job to be kept")
+	}
+}
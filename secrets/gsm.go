@@ -0,0 +1,54 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SecretManager reads the latest version of secrets from GCP Secret Manager.
+type SecretManager struct {
+	Project string
+
+	client *secretmanager.Client
+}
+
+// NewSecretManager creates a Secret Manager provider for project. Options are
+// passed to the underlying client, which is how tests point it at a fake.
+func NewSecretManager(ctx context.Context, project string, opts ...option.ClientOption) (*SecretManager, error) {
+	c, err := secretmanager.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create secret manager client: %w", err)
+	}
+
+	return &SecretManager{Project: project, client: c}, nil
+}
+
+// Name implements Provider.
+func (s *SecretManager) Name() string { return "gsm" }
+
+// Lookup implements Provider.
+func (s *SecretManager) Lookup(ctx context.Context, key string) (string, bool, error) {
+	name := fmt.Sprintf("projects/%s/secrets/%s/versions/latest", s.Project, key)
+	resp, err := s.client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: name})
+	if status.Code(err) == codes.NotFound {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("access %q: %w", name, err)
+	}
+
+	v := strings.TrimSpace(string(resp.GetPayload().GetData()))
+	return v, v != "", nil
+}
+
+// Close closes the underlying client.
+func (s *SecretManager) Close() error {
+	return s.client.Close()
+}
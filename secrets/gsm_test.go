@@ -0,0 +1,68 @@
+package secrets
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+type fakeSecretManager struct {
+	secretmanagerpb.UnimplementedSecretManagerServiceServer
+
+	data map[string]string
+}
+
+func (f *fakeSecretManager) AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	v, ok := f.data[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "secret %q not found", req.GetName())
+	}
+
+	return &secretmanagerpb.AccessSecretVersionResponse{
+		Name:    req.GetName(),
+		Payload: &secretmanagerpb.SecretPayload{Data: []byte(v)},
+	}, nil
+}
+
+func TestSecretManager(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer()
+	secretmanagerpb.RegisterSecretManagerServiceServer(srv, &fakeSecretManager{
+		data: map[string]string{
+			"projects/test/secrets/GQL_TOKEN/versions/latest": "hunter2\n",
+		},
+	})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	ctx := context.Background()
+	sm, err := NewSecretManager(ctx, "test",
+		option.WithEndpoint(lis.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sm.Close() })
+
+	v, ok, err := sm.Lookup(ctx, "GQL_TOKEN")
+	if err != nil || !ok || v != "hunter2" {
+		t.Errorf("expected hunter2, got %q %v %+v", v, ok, err)
+	}
+
+	if _, ok, err := sm.Lookup(ctx, "MISSING"); err != nil || ok {
+		t.Errorf("expected missing secret to not be found, got %v %+v", ok, err)
+	}
+}
@@ -0,0 +1,129 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Env reads secrets from environment variables. Empty values count as unset.
+type Env struct{}
+
+// Name implements Provider.
+func (Env) Name() string { return "env" }
+
+// Lookup implements Provider.
+func (Env) Lookup(ctx context.Context, key string) (string, bool, error) {
+	v := os.Getenv(key)
+	return v, v != "", nil
+}
+
+// Dir reads secrets from files in a directory, one file per secret, named
+// after the key. This is how Cloud Run and Kubernetes mount secrets.
+type Dir struct {
+	Path string
+}
+
+// Name implements Provider.
+func (d *Dir) Name() string { return "file" }
+
+// Lookup implements Provider.
+func (d *Dir) Lookup(ctx context.Context, key string) (string, bool, error) {
+	if strings.ContainsAny(key, `/\`) || key == "." || key == ".." {
+		return "", false, nil
+	}
+
+	b, err := os.ReadFile(filepath.Join(d.Path, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	v := strings.TrimSpace(string(b))
+	return v, v != "", nil
+}
+
+// Chain checks each provider in order and returns the first value found.
+type Chain []Provider
+
+// Name implements Provider.
+func (c Chain) Name() string {
+	names := make([]string, len(c))
+	for i, p := range c {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Lookup implements Provider.
+func (c Chain) Lookup(ctx context.Context, key string) (string, bool, error) {
+	for _, p := range c {
+		v, ok, err := p.Lookup(ctx, key)
+		if err != nil {
+			return "", false, err
+		}
+		if ok {
+			return v, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// Cache remembers lookups from another provider for a TTL, so rotated
+// secrets are picked up without hammering the backend on every run.
+type Cache struct {
+	provider Provider
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	value   string
+	ok      bool
+	expires time.Time
+}
+
+// NewCache wraps p so lookups are cached for ttl.
+func NewCache(p Provider, ttl time.Duration) *Cache {
+	return &Cache{
+		provider: p,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  map[string]cacheEntry{},
+	}
+}
+
+// Name implements Provider.
+func (c *Cache) Name() string { return c.provider.Name() }
+
+// Lookup implements Provider. Errors are never cached.
+func (c *Cache) Lookup(ctx context.Context, key string) (string, bool, error) {
+	c.mu.Lock()
+	e, hit := c.entries[key]
+	c.mu.Unlock()
+	if hit && c.now().Before(e.expires) {
+		return e.value, e.ok, nil
+	}
+
+	v, ok, err := c.provider.Lookup(ctx, key)
+	if err != nil {
+		return "", false, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = cacheEntry{value: v, ok: ok, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+
+	return v, ok, nil
+}
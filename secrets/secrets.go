@@ -0,0 +1,109 @@
+// Package secrets resolves the credentials jobs need to run.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Provider looks up secrets from a backend.
+type Provider interface {
+	// Name identifies the provider in logs and config.
+	Name() string
+
+	// Lookup returns the value of key. The bool is false if the backend does
+	// not have the secret, which is not an error.
+	Lookup(ctx context.Context, key string) (string, bool, error)
+}
+
+// MissingError is returned when one or more secrets could not be found.
+type MissingError struct {
+	Keys []string
+}
+
+func (e *MissingError) Error() string {
+	return fmt.Sprintf("missing secrets: %s", strings.Join(e.Keys, ", "))
+}
+
+// Resolve looks up every key in p and returns their values. If any key is
+// unset, a *MissingError listing all of the absent keys is returned. A nil
+// provider reads from the environment.
+func Resolve(ctx context.Context, p Provider, keys []string) (map[string]string, error) {
+	if p == nil {
+		p = Env{}
+	}
+
+	found := make(map[string]string, len(keys))
+	var missing []string
+	for _, k := range keys {
+		v, ok, err := p.Lookup(ctx, k)
+		if err != nil {
+			return nil, fmt.Errorf("lookup %q in %s: %w", k, p.Name(), err)
+		}
+		if !ok {
+			missing = append(missing, k)
+			continue
+		}
+		found[k] = v
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, &MissingError{Keys: missing}
+	}
+
+	return found, nil
+}
+
+// FromEnv builds the provider chain described by the environment.
+//
+//   - SECRET_PROVIDERS is a comma separated list of "env", "file" and "gsm"
+//     in the order they should be checked. Defaults to "env,file".
+//   - SECRETS_DIR is the directory the file provider reads. Defaults to
+//     "/secrets".
+//   - SECRETS_TTL is how long values are cached. Defaults to five minutes.
+func FromEnv(ctx context.Context, project string) (Provider, error) {
+	order := os.Getenv("SECRET_PROVIDERS")
+	if order == "" {
+		order = "env,file"
+	}
+
+	dir := os.Getenv("SECRETS_DIR")
+	if dir == "" {
+		dir = "/secrets"
+	}
+
+	ttl := 5 * time.Minute
+	if s := os.Getenv("SECRETS_TTL"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("parse SECRETS_TTL: %w", err)
+		}
+		ttl = d
+	}
+
+	var chain Chain
+	for _, name := range strings.Split(order, ",") {
+		switch strings.TrimSpace(name) {
+		case "env":
+			chain = append(chain, Env{})
+		case "file":
+			chain = append(chain, &Dir{Path: dir})
+		case "gsm":
+			sm, err := NewSecretManager(ctx, project)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, sm)
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown secret provider %q", name)
+		}
+	}
+
+	return NewCache(chain, ttl), nil
+}
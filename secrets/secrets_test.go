@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type mapProvider map[string]string
+
+func (m mapProvider) Name() string { return "map" }
+
+func (m mapProvider) Lookup(ctx context.Context, key string) (string, bool, error) {
+	v, ok := m[key]
+	return v, ok, nil
+}
+
+func TestResolve(t *testing.T) {
+	t.Setenv("CRON_TEST_A", "a")
+	t.Setenv("CRON_TEST_B", "")
+
+	got, err := Resolve(context.Background(), nil, []string{"CRON_TEST_A"})
+	if err != nil {
+		t.Fatalf("expected no error, got %+v", err)
+	}
+	if want := map[string]string{"CRON_TEST_A": "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	_, err = Resolve(context.Background(), nil, []string{"CRON_TEST_C", "CRON_TEST_A", "CRON_TEST_B"})
+	var missing *MissingError
+	if !errors.As(err, &missing) {
+		t.Fatalf("expected a MissingError, got %+v", err)
+	}
+	if want := []string{"CRON_TEST_B", "CRON_TEST_C"}; !reflect.DeepEqual(missing.Keys, want) {
+		t.Errorf("expected missing %+v, got %+v", want, missing.Keys)
+	}
+}
+
+func TestChain(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "GITHUB_TOKEN"), []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Chain{
+		mapProvider{"GQL_TOKEN": "from-map"},
+		&Dir{Path: dir},
+	}
+
+	got, err := Resolve(context.Background(), c, []string{"GQL_TOKEN", "GITHUB_TOKEN"})
+	if err != nil {
+		t.Fatalf("expected no error, got %+v", err)
+	}
+	want := map[string]string{"GQL_TOKEN": "from-map", "GITHUB_TOKEN": "from-file"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if _, ok, _ := c.Lookup(context.Background(), "../GITHUB_TOKEN"); ok {
+		t.Errorf("expected paths outside of dir to be ignored")
+	}
+}
+
+func TestCache(t *testing.T) {
+	m := mapProvider{"KEY": "old"}
+	c := NewCache(m, time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	if v, _, _ := c.Lookup(ctx, "KEY"); v != "old" {
+		t.Fatalf("expected old, got %q", v)
+	}
+
+	m["KEY"] = "new"
+	if v, _, _ := c.Lookup(ctx, "KEY"); v != "old" {
+		t.Errorf("expected cached old, got %q", v)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if v, _, _ := c.Lookup(ctx, "KEY"); v != "new" {
+		t.Errorf("expected rotated new, got %q", v)
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cancelWait is how long jobs get to return after their context is canceled
+// before they are given up on.
+const cancelWait = time.Second
+
+// drainer tracks jobs started by the server so shutdown can wait for them.
+type drainer struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	draining bool
+	wg       sync.WaitGroup
+}
+
+func newDrainer() *drainer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &drainer{ctx: ctx, cancel: cancel}
+}
+
+// Draining is true once Drain has been called.
+func (d *drainer) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.draining
+}
+
+func (d *drainer) add() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.draining {
+		return false
+	}
+	d.wg.Add(1)
+	return true
+}
+
+// Do runs fn with a context that is canceled once the grace period runs out.
+// It returns false without running fn if the server is draining.
+func (d *drainer) Do(fn func(ctx context.Context)) bool {
+	if !d.add() {
+		return false
+	}
+	defer d.wg.Done()
+
+	fn(d.ctx)
+	return true
+}
+
+// Go is Do in the background.
+func (d *drainer) Go(fn func(ctx context.Context)) bool {
+	if !d.add() {
+		return false
+	}
+
+	go func() {
+		defer d.wg.Done()
+		fn(d.ctx)
+	}()
+	return true
+}
+
+// Drain stops new jobs from starting and waits up to grace for running ones.
+// Jobs still running after that have their context canceled. It returns
+// false if some jobs did not return even then.
+func (d *drainer) Drain(grace time.Duration) bool {
+	d.mu.Lock()
+	d.draining = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	t := time.NewTimer(grace)
+	defer t.Stop()
+	select {
+	case <-done:
+		return true
+	case <-t.C:
+	}
+
+	d.cancel()
+
+	t.Reset(cancelWait)
+	select {
+	case <-done:
+		return true
+	case <-t.C:
+		return false
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	d := newDrainer()
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	if !d.Go(func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+	}) {
+		t.Fatal("expected job to start before draining")
+	}
+	<-started
+
+	if !d.Drain(10 * time.Millisecond) {
+		t.Error("expected a job that honors cancellation to finish")
+	}
+	select {
+	case <-canceled:
+	default:
+		t.Error("expected job context to be canceled after the grace period")
+	}
+
+	if d.Go(func(context.Context) { t.Error("job ran while draining") }) {
+		t.Error("expected Go to refuse work while draining")
+	}
+	if !d.Draining() {
+		t.Error("expected Draining to be true")
+	}
+}
+
+func TestDrainStubborn(t *testing.T) {
+	d := newDrainer()
+
+	release := make(chan struct{})
+	defer close(release)
+	d.Go(func(context.Context) { <-release })
+
+	if d.Drain(0) {
+		t.Error("expected a job that ignores cancellation to be given up on")
+	}
+}
@@ -1,25 +1,47 @@
-package main
+// Package server runs cron as a long lived service. It takes jobs from
+// Pub/Sub, the scheduler and its HTTP API, and serves their history.
+package server
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
 	"cloud.google.com/go/pubsub"
 	"github.com/dgraph-io/ristretto"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/icco/cron"
+	"github.com/icco/cron/deadletter"
+	"github.com/icco/cron/dedupe"
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/metrics"
+	"github.com/icco/cron/outbox"
+	"github.com/icco/cron/retry"
+	"github.com/icco/cron/runs"
+	"github.com/icco/cron/secrets"
 	"github.com/icco/cron/shared"
 	"github.com/icco/cron/sites"
+	"github.com/icco/cron/tracing"
+	"github.com/icco/cron/transport"
 	"github.com/icco/gutil/logging"
 	"github.com/icco/gutil/render"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
 
+// subscription is the Pub/Sub subscription messages are pulled from.
+const subscription = "cron-client"
+
 var (
 	log = logging.Must(logging.NewLogger(cron.Service))
 
@@ -40,53 +62,284 @@ var (
 `
 )
 
-type PubSubMessage struct {
-	Message struct {
-		Data []byte `json:"data,omitempty"`
-		ID   string `json:"id"`
-	} `json:"message"`
-	Subscription string `json:"subscription"`
-}
-
-func main() {
+// Serve runs the server until ctx is done or it gets SIGINT or SIGTERM, then
+// gives running jobs SHUTDOWN_GRACE to finish and returns. It returns an
+// error if the server could not start.
+func Serve(ctx context.Context) error {
 	port := "8080"
 	if fromEnv := os.Getenv("PORT"); fromEnv != "" {
 		port = fromEnv
 	}
 	log.Infow("Starting up", "host", fmt.Sprintf("http://localhost:%s", port))
 
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	grace := 8 * time.Second
+	if s := os.Getenv("SHUTDOWN_GRACE"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("parse SHUTDOWN_GRACE: %w", err)
+		}
+		grace = d
+	}
+	drain := newDrainer()
+
+	shutdownTracing, err := tracing.Setup(ctx, cron.Service)
+	if err != nil {
+		return fmt.Errorf("set up tracing: %w", err)
+	}
+
+	// Jobs' clients count, time and trace every outbound request.
+	hc, err := shared.NewHTTP(log, metrics.ObserveHTTP)
+	if err != nil {
+		return fmt.Errorf("create http client: %w", err)
+	}
+	hc.Base = tracing.Transport(nil)
+
 	cache, err := ristretto.NewCache(&ristretto.Config{
 		NumCounters: 1e7,     // Num keys to track frequency of (10M).
 		MaxCost:     1 << 30, // Maximum cost of cache (1GB).
 		BufferItems: 64,      // Number of keys per Get buffer.
 	})
 	if err != nil {
-		log.Fatalw("could not create cache", zap.Error(err))
+		return fmt.Errorf("create cache: %w", err)
+	}
+	sp, err := secrets.FromEnv(context.Background(), cron.GCPProject)
+	if err != nil {
+		return fmt.Errorf("create secret provider: %w", err)
+	}
+
+	if os.Getenv("CRON_DATA_DIR") == "" {
+		log.Warnw("CRON_DATA_DIR is not set, so runs, dead letters and the outbox are kept in a temp dir and lost when the instance restarts", "dir", cron.DataDir())
+	}
+	rs, err := runs.OpenFile(filepath.Join(cron.DataDir(), "runs.jsonl"))
+	if err != nil {
+		return fmt.Errorf("open run store: %w", err)
+	}
+
+	window := time.Hour
+	if s := os.Getenv("DEDUPE_WINDOW"); s != "" {
+		window, err = time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("parse DEDUPE_WINDOW: %w", err)
+		}
+	}
+
+	dl, closeDeadLetters, err := openDeadLetters(context.Background(), hc.Client())
+	if err != nil {
+		return fmt.Errorf("open dead letter store: %w", err)
+	}
+	defer func() {
+		if err := closeDeadLetters(); err != nil {
+			log.Errorw("could not close dead letter store", zap.Error(err))
+		}
+	}()
+	deadLetterAfter := cron.DefaultDeadLetterAfter
+	if s := os.Getenv("DEAD_LETTER_AFTER"); s != "" {
+		deadLetterAfter, err = strconv.Atoi(s)
+		if err != nil || deadLetterAfter < 1 {
+			return fmt.Errorf("parse DEAD_LETTER_AFTER: must be a positive number, got %q", s)
+		}
+	}
+
+	ob, err := outbox.Open(filepath.Join(cron.DataDir(), "outbox.jsonl"))
+	if err != nil {
+		return fmt.Errorf("open outbox: %w", err)
 	}
+	metrics.WatchOutbox(func() (int, time.Time) {
+		s := ob.Stats()
+		if s.Oldest == nil {
+			return s.Depth, time.Time{}
+		}
+		return s.Depth, s.Oldest.Added
+	})
+
 	cfg := &cron.Config{
-		Config: shared.Config{Log: log},
-		Cache:  cache,
+		Config:      shared.Config{Log: log, HTTP: hc, Outbox: ob},
+		Cache:       cache,
+		Secrets:     sp,
+		Runs:        rs,
+		Dedupe:      dedupe.NewWindow(window),
+		DeadLetters: dl,
+
+		DeadLetterAfter: deadLetterAfter,
 	}
 
-	if os.Getenv("USE_HTTP") == "" {
+	loc, jitter, err := schedulerConfig()
+	if err != nil {
+		return err
+	}
+	sched, err := cfg.NewScheduler(loc, jitter)
+	if err != nil {
+		return fmt.Errorf("create scheduler: %w", err)
+	}
+	// Scheduled jobs run on the drainer's context, so stopping the scheduler
+	// doesn't cancel jobs it already started.
+	sched.Dispatch = func(_ context.Context, job string) error {
+		var err error
+		if !drain.Do(func(ctx context.Context) { err = cfg.Act(ctx, &cron.Message{Job: job}) }) {
+			return fmt.Errorf("not running %q: shutting down", job)
+		}
+		return err
+	}
+	if os.Getenv("SCHEDULER") != "" {
 		go func() {
-			ctx := context.Background()
-			for {
-				if err := recieveMessages(ctx, "cron-client", cfg); err != nil {
-					log.Errorw("could not process message", zap.Error(err))
-				}
+			if err := sched.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Errorw("scheduler stopped", zap.Error(err))
 			}
 		}()
 	}
 
+	flusher, err := newFlusher(shared.Config{Log: log, HTTP: hc}, ob, sp)
+	if err != nil {
+		return fmt.Errorf("create outbox flusher: %w", err)
+	}
+	go func() {
+		if err := flusher.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Errorw("outbox flusher stopped", zap.Error(err))
+		}
+	}()
+
+	tr, err := openTransport(ctx)
+	if err != nil {
+		return fmt.Errorf("open transport: %w", err)
+	}
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for ctx.Err() == nil {
+			if err := tr.Receive(ctx, handleMessage(cfg, drain)); err != nil {
+				log.Errorw("could not receive messages", zap.Error(err))
+				select {
+				case <-ctx.Done():
+				case <-time.After(5 * time.Second):
+				}
+			}
+		}
+	}()
+
 	r := chi.NewRouter()
 	r.Use(middleware.RealIP)
 	r.Use(logging.Middleware(log.Desugar(), cron.GCPProject))
 
 	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if drain.Draining() {
+			render.JSON(log, w, http.StatusServiceUnavailable, map[string]string{"status": "draining"})
+			return
+		}
 		render.JSON(log, w, http.StatusOK, map[string]string{"status": "ok"})
 	})
 
+	r.Handle("/metrics", promhttp.Handler())
+
+	r.Get("/jobs", func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(log, w, http.StatusOK, jobs.Describe())
+	})
+
+	r.Get("/runs", func(w http.ResponseWriter, r *http.Request) {
+		f := runs.Filter{
+			Job:    r.URL.Query().Get("job"),
+			Status: runs.Status(r.URL.Query().Get("status")),
+		}
+		if l := r.URL.Query().Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n < 1 {
+				render.JSON(log, w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive number"})
+				return
+			}
+			f.Limit = n
+		}
+
+		list, err := rs.List(r.Context(), f)
+		if err != nil {
+			log.Errorw("could not list runs", zap.Error(err))
+			render.JSON(log, w, http.StatusInternalServerError, map[string]string{"error": "could not list runs"})
+			return
+		}
+
+		render.JSON(log, w, http.StatusOK, list)
+	})
+
+	r.Get("/runs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		run, err := rs.Get(r.Context(), chi.URLParam(r, "id"))
+		if errors.Is(err, runs.ErrNotFound) {
+			render.JSON(log, w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Errorw("could not get run", zap.Error(err))
+			render.JSON(log, w, http.StatusInternalServerError, map[string]string{"error": "could not get run"})
+			return
+		}
+
+		render.JSON(log, w, http.StatusOK, run)
+	})
+
+	r.Get("/deadletters", func(w http.ResponseWriter, r *http.Request) {
+		list, err := dl.List(r.Context())
+		if err != nil {
+			log.Errorw("could not list dead letters", zap.Error(err))
+			render.JSON(log, w, http.StatusInternalServerError, map[string]string{"error": "could not list dead letters"})
+			return
+		}
+
+		render.JSON(log, w, http.StatusOK, list)
+	})
+
+	r.Post("/deadletters/{id}/replay", func(w http.ResponseWriter, r *http.Request) {
+		if drain.Draining() {
+			render.JSON(log, w, http.StatusServiceUnavailable, map[string]string{"error": "shutting down"})
+			return
+		}
+
+		msg, err := cfg.Replay(r.Context(), chi.URLParam(r, "id"))
+		if errors.Is(err, deadletter.ErrNotFound) {
+			render.JSON(log, w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Errorw("could not replay dead letter", zap.Error(err))
+			render.JSON(log, w, http.StatusInternalServerError, map[string]string{"error": "could not replay dead letter"})
+			return
+		}
+
+		if !drain.Go(func(ctx context.Context) {
+			if err := cfg.Act(ctx, msg); err != nil {
+				log.Errorw("error replaying job", zap.Error(err), "job", msg.Job)
+			}
+		}) {
+			log.Errorw("shut down before replaying dead letter", "job", msg.Job, "args", msg.Args)
+			render.JSON(log, w, http.StatusServiceUnavailable, map[string]string{"error": "shutting down"})
+			return
+		}
+
+		render.JSON(log, w, http.StatusAccepted, msg)
+	})
+
+	r.Delete("/deadletters/{id}", func(w http.ResponseWriter, r *http.Request) {
+		err := dl.Remove(r.Context(), chi.URLParam(r, "id"))
+		if errors.Is(err, deadletter.ErrNotFound) {
+			render.JSON(log, w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Errorw("could not discard dead letter", zap.Error(err))
+			render.JSON(log, w, http.StatusInternalServerError, map[string]string{"error": "could not discard dead letter"})
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	r.Get("/outbox", func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(log, w, http.StatusOK, ob.Stats())
+	})
+
+	r.Get("/schedule", func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(log, w, http.StatusOK, sched.Entries())
+	})
+
 	r.Get("/sites", func(w http.ResponseWriter, r *http.Request) {
 		render.JSON(log, w, http.StatusOK, sites.All)
 	})
@@ -98,6 +351,7 @@ func main() {
 		}
 
 		data := []string{
+			fmt.Sprintf("%d jobs", len(jobs.All())),
 			fmt.Sprintf("%d sites", len(sites.All)),
 		}
 		if err := tmpl.Execute(w, data); err != nil {
@@ -105,79 +359,265 @@ func main() {
 		}
 	})
 
-	r.Post("/sub", func(w http.ResponseWriter, r *http.Request) {
-		var event PubSubMessage
-		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
-			log.Errorw("could not decode request", zap.Error(err))
-			http.Error(w, "body decode error", http.StatusInternalServerError)
-			return
+	switch tr := tr.(type) {
+	case *transport.Push:
+		r.Method(http.MethodPost, "/sub", tr)
+	case *transport.Memory:
+		r.Post("/publish", func(w http.ResponseWriter, r *http.Request) {
+			data, err := io.ReadAll(r.Body)
+			if err != nil {
+				render.JSON(log, w, http.StatusBadRequest, map[string]string{"error": "could not read body"})
+				return
+			}
+			if _, err := cron.ParseMessage(data); err != nil {
+				render.JSON(log, w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+
+			render.JSON(log, w, http.StatusAccepted, map[string]string{"id": tr.Publish(data, tracing.Inject(r.Context()))})
+		})
+	}
+
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	serveErr := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
 		}
+	}()
 
-		go func() {
-			ctx := context.Background()
-			if err := parseMsg(ctx, cfg, event.Message.Data); err != nil {
-				log.Errorw("error running job", zap.Error(err), "unparsed", string(event.Message.Data))
-			}
-		}()
+	var listenErr error
+	select {
+	case <-ctx.Done():
+	case listenErr = <-serveErr:
+		log.Errorw("could not serve", zap.Error(listenErr))
+	}
+	stop()
+	log.Infow("shutting down", "active", cfg.Active(), "grace", grace)
 
-		fmt.Fprintf(w, "success")
-	})
+	if !drain.Drain(grace) {
+		n := cfg.Interrupt(context.Background())
+		log.Warnw("jobs did not stop in time", "interrupted", n)
+	}
+	<-received
+	if c, ok := tr.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Errorw("could not close transport", zap.Error(err))
+		}
+	}
+
+	sctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		log.Errorw("could not shut down http server", zap.Error(err))
+	}
+
+	if err := rs.Close(); err != nil {
+		log.Errorw("could not close run store", zap.Error(err))
+	}
+
+	if err := ob.Close(); err != nil {
+		log.Errorw("could not close outbox", zap.Error(err))
+	}
 
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	tctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdownTracing(tctx); err != nil {
+		log.Errorw("could not flush traces", zap.Error(err))
+	}
+	log.Info("shut down")
+
+	if listenErr != nil {
+		return fmt.Errorf("serve: %w", listenErr)
+	}
+
+	return nil
 }
 
-func recieveMessages(ctx context.Context, subName string, cfg *cron.Config) error {
-	pubsubClient, err := pubsub.NewClient(ctx, cron.GCPProject)
-	if err != nil {
-		return fmt.Errorf("create pubsub client: %w", err)
+// openTransport picks where messages come from with TRANSPORT:
+//
+//   - pull, the default, pulls from the cron-client subscription.
+//   - push takes Pub/Sub push requests on /sub. USE_HTTP=1 does the same.
+//   - emulator pulls from the Pub/Sub emulator at PUBSUB_EMULATOR_HOST, and
+//     is the default when that is set.
+//   - memory keeps a queue in memory that messages are added to on /publish.
+func openTransport(ctx context.Context) (transport.Transport, error) {
+	kind := os.Getenv("TRANSPORT")
+	if kind == "" {
+		switch {
+		case os.Getenv("USE_HTTP") != "":
+			kind = "push"
+		case os.Getenv("PUBSUB_EMULATOR_HOST") != "":
+			kind = "emulator"
+		default:
+			kind = "pull"
+		}
 	}
 
-	sub := pubsubClient.Subscription(subName)
-	ok, err := sub.Exists(ctx)
-	if err != nil {
-		return fmt.Errorf("could not check exist of sub: %w", err)
+	switch kind {
+	case "pull":
+		client, err := pubsub.NewClient(ctx, cron.GCPProject)
+		if err != nil {
+			return nil, fmt.Errorf("create pubsub client: %w", err)
+		}
+		return transport.NewPull(client, cron.Service, subscription), nil
+	case "push":
+		return transport.NewPush(), nil
+	case "emulator":
+		host := os.Getenv("PUBSUB_EMULATOR_HOST")
+		if host == "" {
+			return nil, errors.New("PUBSUB_EMULATOR_HOST must be set to use the emulator")
+		}
+		return transport.NewEmulator(ctx, host, cron.GCPProject, cron.Service, subscription)
+	case "memory":
+		return transport.NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown TRANSPORT %q", kind)
 	}
-	if !ok {
-		if _, err := pubsubClient.CreateSubscription(ctx, subName, pubsub.SubscriptionConfig{
-			Topic: pubsubClient.Topic("cron"),
-		}); err != nil {
-			return fmt.Errorf("could not create sub: %w", err)
+}
+
+// handleMessage runs the job a message asks for. Permanent failures, skips
+// and duplicates would go the same way on redelivery, and dead letters are
+// kept elsewhere, so only retryable failures and messages that come in while
+// shutting down are nacked.
+func handleMessage(cfg *cron.Config, drain *drainer) transport.Handler {
+	return func(_ context.Context, msg *transport.Message) transport.Result {
+		cfg.Log.Debugw("got message", "id", msg.ID, "data", string(msg.Data))
+		metrics.PubSubMessages.WithLabelValues(metrics.Received).Inc()
+
+		// Receivers' contexts are canceled as soon as shutdown starts, so
+		// jobs run on the drainer's, which lasts through the grace period.
+		var err error
+		if !drain.Do(func(ctx context.Context) {
+			err = parseMsg(tracing.Extract(ctx, msg.Attributes), cfg, msg.ID, msg.Data)
+		}) {
+			metrics.PubSubMessages.WithLabelValues(metrics.Nacked).Inc()
+			return transport.Nack
+		}
+
+		if err != nil {
+			cfg.Log.Errorw("error running job", zap.Error(err), "unparsed", string(msg.Data))
 		}
+		// A duplicate of a message that is still running, like a push
+		// redelivered after its ack deadline, waits for the original's
+		// outcome: if that fails the message must still be retried.
+		if errors.Is(err, cron.ErrInFlight) {
+			metrics.PubSubMessages.WithLabelValues(metrics.Nacked).Inc()
+			return transport.Nack
+		}
+		if err != nil && retry.ClassOf(err) != retry.ClassPermanent && !errors.Is(err, cron.ErrSkipped) && !errors.Is(err, cron.ErrDuplicate) && !errors.Is(err, cron.ErrDeadLettered) {
+			metrics.PubSubMessages.WithLabelValues(metrics.Nacked).Inc()
+			return transport.Nack
+		}
+		metrics.PubSubMessages.WithLabelValues(metrics.Acked).Inc()
+		return transport.Ack
 	}
+}
 
-	scfg, err := sub.Config(ctx)
+// schedulerConfig reads the scheduler's timezone from SCHEDULER_TZ (default
+// UTC) and jitter from SCHEDULER_JITTER (default 30s).
+func schedulerConfig() (*time.Location, time.Duration, error) {
+	loc := time.UTC
+	if tz := os.Getenv("SCHEDULER_TZ"); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, 0, fmt.Errorf("load SCHEDULER_TZ: %w", err)
+		}
+		loc = l
+	}
+
+	jitter := 30 * time.Second
+	if s := os.Getenv("SCHEDULER_JITTER"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, 0, fmt.Errorf("parse SCHEDULER_JITTER: %w", err)
+		}
+		jitter = d
+	}
+
+	return loc, jitter, nil
+}
+
+// openDeadLetters opens the file backed dead letter store. If
+// DEAD_LETTER_TOPIC is set, entries are also published there, and if
+// DEAD_LETTER_HOOK is set, it is POSTed each new entry. The returned func
+// closes the store.
+func openDeadLetters(ctx context.Context, client *http.Client) (deadletter.Store, func() error, error) {
+	f, err := deadletter.OpenFile(filepath.Join(cron.DataDir(), "deadletters.jsonl"))
 	if err != nil {
-		return fmt.Errorf("could not get sub.Config: %w", err)
+		return nil, nil, err
 	}
-	log.Debugw("got subscription config", "config", scfg, "subscription", subName)
 
-	if err := sub.Receive(ctx, dealWithMessage(cfg)); err != nil && err != context.Canceled {
-		return fmt.Errorf("recieving messages: %w", err)
+	var dl deadletter.Store = f
+	closeAll := f.Close
+
+	if t := os.Getenv("DEAD_LETTER_TOPIC"); t != "" {
+		client, err := pubsub.NewClient(ctx, cron.GCPProject)
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("create pubsub client: %w", err)
+		}
+		topic := client.Topic(t)
+		dl = deadletter.WithTopic(dl, topic)
+		closeAll = func() error {
+			topic.Stop()
+			return errors.Join(client.Close(), f.Close())
+		}
 	}
 
-	return nil
+	if u := os.Getenv("DEAD_LETTER_HOOK"); u != "" {
+		dl = deadletter.WithNotifier(dl, deadletter.Webhook(client, u), func(err error) {
+			log.Errorw("could not notify about dead letter", zap.Error(err))
+		})
+	}
+
+	return dl, closeAll, nil
 }
 
-func dealWithMessage(cfg *cron.Config) func(ctx context.Context, msg *pubsub.Message) {
-	return func(ctx context.Context, msg *pubsub.Message) {
-		cfg.Log.Debugw("got message", "msg", msg)
-		if err := parseMsg(ctx, cfg, msg.Data); err != nil {
-			msg.Nack()
-			return
+// newFlusher creates a flusher that sends the outbox to the GraphQL API every
+// OUTBOX_INTERVAL (default 1m), backing off items that keep failing.
+func newFlusher(cfg shared.Config, ob *outbox.Outbox, sp secrets.Provider) (*outbox.Flusher, error) {
+	interval := time.Minute
+	if s := os.Getenv("OUTBOX_INTERVAL"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("parse OUTBOX_INTERVAL: %w", err)
 		}
-		msg.Ack()
+		interval = d
 	}
+
+	return &outbox.Flusher{
+		Log:      cfg.Log,
+		Outbox:   ob,
+		Interval: interval,
+		Backoff: retry.Policy{
+			Initial:    time.Minute,
+			Max:        time.Hour,
+			Multiplier: 2,
+			Jitter:     0.2,
+		},
+		Send: func(ctx context.Context, query string, vars map[string]any) error {
+			vals, err := secrets.Resolve(ctx, sp, []string{"GQL_TOKEN"})
+			if err != nil {
+				return fmt.Errorf("resolve GQL_TOKEN: %w", err)
+			}
+
+			// cfg has no outbox, or a failed send would be queued again.
+			return shared.NewGraphQL(cfg, vals["GQL_TOKEN"]).Do(ctx, query, vars, nil)
+		},
+	}, nil
 }
 
-func parseMsg(ctx context.Context, cfg *cron.Config, msg []byte) error {
-	data := map[string]string{}
-	if err := json.Unmarshal(msg, &data); err != nil {
-		return fmt.Errorf("parsed json: %w", err)
+func parseMsg(ctx context.Context, cfg *cron.Config, id string, data []byte) error {
+	msg, err := cron.ParseMessage(data)
+	if err != nil {
+		return err
 	}
+	msg.ID = id
 
-	log.Debugw("got message", "parsed", data, "unparsed", string(msg))
-	if err := cfg.Act(ctx, data["job"]); err != nil {
-		return fmt.Errorf("could not run %q: %w", data["job"], err)
+	log.Debugw("got message", "parsed", msg, "unparsed", string(data))
+	if err := cfg.Act(ctx, msg); err != nil {
+		return fmt.Errorf("could not run %q: %w", msg.Job, err)
 	}
 
 	return nil
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/icco/cron"
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/retry"
+	"github.com/icco/cron/runs"
+	"github.com/icco/cron/shared"
+	"github.com/icco/cron/transport"
+	"go.uber.org/zap"
+)
+
+func TestHandleMessage(t *testing.T) {
+	jobs.Register(jobs.New("test-flaky", "", func(ctx context.Context, cfg *jobs.Config) error {
+		return errors.New("connection reset")
+	}, jobs.WithRetry(retry.Never)))
+
+	cfg := &cron.Config{
+		Config: shared.Config{Log: zap.NewNop().Sugar()},
+		Runs:   runs.NewMemory(),
+	}
+
+	for _, tc := range []struct {
+		name string
+		data string
+		want transport.Result
+	}{
+		{"success", `{"job":"minute"}`, transport.Ack},
+		{"bad json", `{`, transport.Ack},
+		{"unknown job", `{"job":"nope"}`, transport.Ack},
+		{"retryable failure", `{"job":"test-flaky"}`, transport.Nack},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := handleMessage(cfg, newDrainer())
+			if got := h(context.Background(), &transport.Message{Data: []byte(tc.data)}); got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("draining", func(t *testing.T) {
+		d := newDrainer()
+		d.Drain(0)
+		if got := handleMessage(cfg, d)(context.Background(), &transport.Message{Data: []byte(`{"job":"minute"}`)}); got != transport.Nack {
+			t.Errorf("expected messages to be nacked while draining, got %s", got)
+		}
+	})
+}
+
+func TestHandleMessageMemory(t *testing.T) {
+	cfg := &cron.Config{
+		Config: shared.Config{Log: zap.NewNop().Sugar()},
+		Runs:   runs.NewMemory(),
+	}
+	m := transport.NewMemory()
+	m.Publish([]byte(`{"job":"minute"}`), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	h := handleMessage(cfg, newDrainer())
+	go func() {
+		defer close(done)
+		m.Receive(ctx, func(ctx context.Context, msg *transport.Message) transport.Result {
+			defer cancel()
+			return h(ctx, msg)
+		})
+	}()
+	<-done
+
+	if n := m.Pending(); n != 0 {
+		t.Errorf("expected the message to be acked, %d pending", n)
+	}
+	list, err := cfg.Runs.List(context.Background(), runs.Filter{Job: "minute"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Status != runs.Succeeded {
+		t.Errorf("expected one successful run, got %+v", list)
+	}
+}
@@ -0,0 +1,268 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/icco/cron/tracing"
+	gql "github.com/icco/graphql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// DefaultBatchSize is how many upserts go in one request unless
+// GQL_BATCH_SIZE says otherwise.
+const DefaultBatchSize = 50
+
+// Upsert is one mutation that can be sent in a batch.
+type Upsert struct {
+	// Field is the mutation, like "upsertBook".
+	Field string
+
+	// Type is the GraphQL type of Input, like "EditBook!".
+	Type string
+
+	Input any
+
+	// Select is what to ask for back, like "id".
+	Select string
+}
+
+// StatUpsert is an Upsert that saves a stat.
+func StatUpsert(s gql.NewStat) Upsert {
+	return Upsert{Field: "upsertStat", Type: "NewStat!", Input: s, Select: "when"}
+}
+
+// BookUpsert is an Upsert that saves a book.
+func BookUpsert(b gql.EditBook) Upsert {
+	return Upsert{Field: "upsertBook", Type: "EditBook!", Input: b, Select: "id"}
+}
+
+// LinkUpsert is an Upsert that saves a link.
+func LinkUpsert(l gql.NewLink) Upsert {
+	return Upsert{Field: "upsertLink", Type: "NewLink!", Input: l, Select: "id"}
+}
+
+// TweetUpsert is an Upsert that saves a tweet.
+func TweetUpsert(t gql.NewTweet) Upsert {
+	return Upsert{Field: "upsertTweet", Type: "NewTweet!", Input: t, Select: "id"}
+}
+
+// BatchError is returned by Batch when some upserts failed. The others were
+// saved.
+type BatchError struct {
+	// Failed maps the index of each failed upsert to why it failed.
+	Failed map[int]error
+}
+
+func (e *BatchError) Error() string {
+	idx := e.Indexes()
+	return fmt.Sprintf("graphql: %d upsert(s) failed, first #%d: %v", len(idx), idx[0], e.Failed[idx[0]])
+}
+
+// Unwrap returns the error of every failed upsert.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, i := range e.Indexes() {
+		errs = append(errs, e.Failed[i])
+	}
+
+	return errs
+}
+
+// Indexes returns the indexes of the failed upserts in order.
+func (e *BatchError) Indexes() []int {
+	idx := make([]int, 0, len(e.Failed))
+	for i := range e.Failed {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+
+	return idx
+}
+
+// Batch sends upserts in as few requests as it can, packing up to BatchSize
+// of them into each as aliased mutations. If some upserts are rejected, the
+// rest are still saved and a *BatchError says which failed. Any other error
+// stops the batch, unless there is an outbox, in which case the upserts of
+// that chunk and the rest are queued in it one by one, so one the API later
+// rejects is dropped alone. In a dry run each upsert is recorded.
+func (c *GraphQL) Batch(ctx context.Context, upserts []Upsert) error {
+	if c.DryRun != nil {
+		for _, u := range upserts {
+			c.DryRun.Record(WriteGraphQL, u.Field, u.Input)
+		}
+		return nil
+	}
+
+	size := c.BatchSize
+	if size < 1 {
+		size = DefaultBatchSize
+	}
+
+	failed := map[int]error{}
+	queued := false
+	for start := 0; start < len(upserts); start += size {
+		end := min(start+size, len(upserts))
+
+		// The API just failed, so don't wait on it again for every chunk.
+		if queued {
+			if err := c.enqueueEach(ctx, upserts[start:end]); err != nil {
+				return fmt.Errorf("queue graphql batch: %w", err)
+			}
+			continue
+		}
+
+		err := c.batch(ctx, upserts[start:end], start, failed)
+		if errors.Is(err, errQueued) {
+			queued = true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(failed) > 0 {
+		return &BatchError{Failed: failed}
+	}
+
+	return nil
+}
+
+// batch sends one chunk of upserts, which start at offset in the whole
+// batch, and records rejected ones in failed.
+func (c *GraphQL) batch(ctx context.Context, upserts []Upsert, offset int, failed map[int]error) error {
+	ctx, span := tracing.Start(ctx, "graphql batch", attribute.Int("graphql.batch_size", len(upserts)))
+	defer span.End()
+
+	query, vars := batchDocument(upserts)
+	err := c.Do(ctx, query, vars, nil)
+
+	var re *ResponseError
+	if !errors.As(err, &re) {
+		err = c.queueEach(ctx, upserts, err)
+		if errors.Is(err, errQueued) {
+			span.SetAttributes(attribute.Bool("graphql.queued", true))
+			return err
+		}
+		return tracing.Error(span, err)
+	}
+
+	// Errors with a path belong to one alias. One without a path means the
+	// whole document was rejected, usually because an input didn't match its
+	// type, so each upsert not already known to be bad is sent alone to find
+	// the rest.
+	whole := false
+	for _, ge := range re.Errors {
+		i, ok := aliasIndex(ge.Path)
+		if !ok || i >= len(upserts) {
+			whole = true
+			continue
+		}
+
+		failed[offset+i] = &ResponseError{Errors: []GraphQLError{ge}}
+	}
+	if !whole {
+		span.SetAttributes(attribute.Int("graphql.failed", len(re.Errors)))
+		return nil
+	}
+	if len(upserts) == 1 {
+		failed[offset] = re
+		return nil
+	}
+
+	// An upsert that is queued doesn't stop the others from being sent.
+	queued := false
+	for k := range upserts {
+		if _, ok := failed[offset+k]; ok {
+			continue
+		}
+
+		err := c.batch(ctx, upserts[k:k+1], offset+k, failed)
+		if errors.Is(err, errQueued) {
+			queued = true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if queued {
+		return errQueued
+	}
+
+	return nil
+}
+
+// queueEach is queue for a chunk of upserts. Each is queued as its own
+// mutation, as the outbox drops an item the API rejects.
+func (c *GraphQL) queueEach(ctx context.Context, upserts []Upsert, err error) error {
+	if !c.canQueue(ctx, err) {
+		return err
+	}
+
+	if qerr := c.enqueueEach(ctx, upserts); qerr != nil {
+		return fmt.Errorf("%w (could not queue it: %v)", err, qerr)
+	}
+	if c.Log != nil {
+		c.Log.Warnw("queued graphql upserts for later", "count", len(upserts), zap.Error(err))
+	}
+
+	return errQueued
+}
+
+// enqueueEach adds each upsert to the outbox as its own mutation.
+func (c *GraphQL) enqueueEach(ctx context.Context, upserts []Upsert) error {
+	for i := range upserts {
+		query, vars := batchDocument(upserts[i : i+1])
+		if err := c.Outbox.Enqueue(ctx, query, vars); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// batchDocument builds a mutation with each upsert aliased as u0, u1 and so
+// on, taking its input from the variable of the same name.
+func batchDocument(upserts []Upsert) (string, map[string]any) {
+	params := make([]string, len(upserts))
+	fields := make([]string, len(upserts))
+	vars := make(map[string]any, len(upserts))
+	for i, u := range upserts {
+		a := "u" + strconv.Itoa(i)
+		params[i] = fmt.Sprintf("$%s: %s", a, u.Type)
+		fields[i] = fmt.Sprintf("  %s: %s(input: $%s) {\n    %s\n  }", a, u.Field, a, u.Select)
+		vars[a] = u.Input
+	}
+
+	return fmt.Sprintf("mutation (%s) {\n%s\n}", strings.Join(params, ", "), strings.Join(fields, "\n")), vars
+}
+
+// aliasIndex returns i if path points at alias ui, either as a field in the
+// response or, for inputs that failed validation, as a variable.
+func aliasIndex(path []any) (int, bool) {
+	if len(path) > 1 && path[0] == "variable" {
+		path = path[1:]
+	}
+	if len(path) == 0 {
+		return 0, false
+	}
+
+	s, ok := path[0].(string)
+	if !ok || !strings.HasPrefix(s, "u") {
+		return 0, false
+	}
+
+	i, err := strconv.Atoi(s[1:])
+	if err != nil || i < 0 {
+		return 0, false
+	}
+
+	return i, true
+}
@@ -0,0 +1,179 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/icco/cron/retry"
+	gql "github.com/icco/graphql"
+)
+
+func TestBatchDocument(t *testing.T) {
+	q, vars := batchDocument([]Upsert{
+		StatUpsert(gql.NewStat{Key: "a"}),
+		BookUpsert(gql.EditBook{}),
+	})
+
+	want := `mutation ($u0: NewStat!, $u1: EditBook!) {
+  u0: upsertStat(input: $u0) {
+    when
+  }
+  u1: upsertBook(input: $u1) {
+    id
+  }
+}`
+	if q != want {
+		t.Errorf("got:\n%s\nwant:\n%s", q, want)
+	}
+	if len(vars) != 2 {
+		t.Errorf("got %d variables, want 2", len(vars))
+	}
+}
+
+func TestBatch(t *testing.T) {
+	var requests []int
+	c := testGraphQL(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]gql.NewStat
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, len(req.Variables))
+
+		// Stats with a key of "bad" are rejected, and "invalid" ones fail
+		// validation, which rejects the whole document.
+		var errs []string
+		for alias, s := range req.Variables {
+			switch s.Key {
+			case "bad":
+				errs = append(errs, fmt.Sprintf(`{"message": "bad stat", "path": [%q]}`, alias))
+			case "invalid":
+				errs = append(errs, `{"message": "invalid document"}`)
+			}
+		}
+		if len(errs) > 0 {
+			fmt.Fprintf(w, `{"errors": [%s]}`, strings.Join(errs, ","))
+			return
+		}
+		w.Write([]byte(`{"data": {}}`))
+	})
+	c.BatchSize = 2
+
+	var upserts []Upsert
+	for _, k := range []string{"a", "bad", "b", "c", "invalid"} {
+		upserts = append(upserts, StatUpsert(gql.NewStat{Key: k}))
+	}
+
+	err := c.Batch(context.Background(), upserts)
+	var be *BatchError
+	if !errors.As(err, &be) {
+		t.Fatalf("expected a BatchError, got %+v", err)
+	}
+	if got := fmt.Sprint(be.Indexes()); got != "[1 4]" {
+		t.Errorf("failed upserts = %s, want [1 4]", got)
+	}
+	if !strings.Contains(be.Failed[1].Error(), "bad stat") {
+		t.Errorf("upsert 1 failed with %v", be.Failed[1])
+	}
+
+	// Two full chunks, then the last one alone, which is already as small as
+	// it can get when its document is rejected.
+	if got := fmt.Sprint(requests); got != "[2 2 1]" {
+		t.Errorf("request sizes = %s, want [2 2 1]", got)
+	}
+}
+
+func TestBatchIsolatesInvalid(t *testing.T) {
+	c := testGraphQL(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]gql.NewStat
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		for alias, s := range req.Variables {
+			if s.Key == "invalid" {
+				fmt.Fprintf(w, `{"errors": [{"message": "bad value", "path": ["variable", %q, "key"]}]}`, alias)
+				return
+			}
+		}
+		w.Write([]byte(`{"data": {}}`))
+	})
+
+	upserts := []Upsert{
+		StatUpsert(gql.NewStat{Key: "a"}),
+		StatUpsert(gql.NewStat{Key: "invalid"}),
+		StatUpsert(gql.NewStat{Key: "b"}),
+	}
+
+	err := c.Batch(context.Background(), upserts)
+	var be *BatchError
+	if !errors.As(err, &be) || fmt.Sprint(be.Indexes()) != "[1]" {
+		t.Fatalf("expected only upsert 1 to fail, got %+v", err)
+	}
+}
+
+func TestBatchQueuesResends(t *testing.T) {
+	var mu sync.Mutex
+	sent := map[string]int{}
+	c := testGraphQL(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]gql.NewStat
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+
+		// The whole document is rejected, "bad" has its own error as well,
+		// and "down" can't be sent when it is sent alone.
+		if len(req.Variables) > 1 {
+			var errs []string
+			for alias, s := range req.Variables {
+				if s.Key == "bad" {
+					errs = append(errs, fmt.Sprintf(`{"message": "bad stat", "path": [%q]}`, alias))
+				}
+			}
+			errs = append(errs, `{"message": "invalid document"}`)
+			fmt.Fprintf(w, `{"errors": [%s]}`, strings.Join(errs, ","))
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, s := range req.Variables {
+			sent[s.Key]++
+			if s.Key == "down" {
+				http.Error(w, "down", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Write([]byte(`{"data": {}}`))
+	})
+	c.Retry = retry.Never
+	q := &fakeQueue{}
+	c.Outbox = q
+
+	var upserts []Upsert
+	for _, k := range []string{"a", "down", "bad", "b"} {
+		upserts = append(upserts, StatUpsert(gql.NewStat{Key: k}))
+	}
+
+	err := c.Batch(context.Background(), upserts)
+	var be *BatchError
+	if !errors.As(err, &be) || fmt.Sprint(be.Indexes()) != "[2]" {
+		t.Fatalf("expected only upsert 2 to fail, got %+v", err)
+	}
+	if got := fmt.Sprint(sent); got != "map[a:1 b:1 down:1]" {
+		t.Errorf("sent alone = %s, want every upsert but the bad one once", got)
+	}
+	if len(q.queries) != 1 {
+		t.Errorf("expected the upsert that couldn't be sent to be queued, got %d queued", len(q.queries))
+	}
+}
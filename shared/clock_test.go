@@ -0,0 +1,21 @@
+package shared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigNow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	cfg := Config{Clock: clock}
+
+	clock.Advance(90 * time.Minute)
+	if got := cfg.Now(); !got.Equal(start.Add(90 * time.Minute)) {
+		t.Errorf("Now() = %s after advancing 90m from %s", got, start)
+	}
+
+	if got := (Config{}).Now(); time.Since(got) > time.Minute {
+		t.Errorf("expected a config without a clock to use the system clock, got %s", got)
+	}
+}
@@ -1,7 +1,43 @@
 package shared
 
-import "go.uber.org/zap"
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
 
 type Config struct {
 	Log *zap.SugaredLogger
+
+	// Clock tells the time. Nil means the system clock.
+	Clock Clock
+
+	// HTTP makes the clients jobs talk to other services with.
+	HTTP *HTTP
+
+	// Outbox, if set, keeps GraphQL writes that fail while the API is down.
+	Outbox Queue
+
+	// DryRun, if set, records writes instead of making them.
+	DryRun *Recorder
+}
+
+// HTTPClient returns a client from c.HTTP, or one with just a timeout and
+// our User-Agent if it isn't set.
+func (c Config) HTTPClient() *http.Client {
+	if c.HTTP == nil {
+		return defaultHTTP.Client()
+	}
+
+	return c.HTTP.Client()
+}
+
+// Now returns the time according to c.Clock.
+func (c Config) Now() time.Time {
+	if c.Clock == nil {
+		return time.Now()
+	}
+
+	return c.Clock.Now()
 }
@@ -0,0 +1,77 @@
+package shared
+
+import (
+	"sort"
+	"sync"
+)
+
+// Kinds of writes a Recorder sees.
+const (
+	WriteGraphQL    = "graphql"
+	WriteHTTP       = "http"
+	WriteCloudBuild = "cloudbuild"
+)
+
+// Write is something a job would have changed if it weren't a dry run.
+type Write struct {
+	// Kind is the sort of write, like WriteGraphQL.
+	Kind string `json:"kind"`
+
+	// Target is what would have been written to, like a mutation or URL.
+	Target string `json:"target"`
+
+	Payload any `json:"payload,omitempty"`
+}
+
+// Summary describes the writes of a dry run.
+type Summary struct {
+	Total  int            `json:"total"`
+	ByKind map[string]int `json:"by_kind,omitempty"`
+	Writes []Write        `json:"writes,omitempty"`
+}
+
+// Recorder takes the place of a job's writes in a dry run. Jobs fetch and
+// transform as usual, and every write they would have made is recorded
+// instead. It is safe for concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	writes []Write
+}
+
+// Record notes a write that was not made.
+func (r *Recorder) Record(kind, target string, payload any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.writes = append(r.writes, Write{Kind: kind, Target: target, Payload: payload})
+}
+
+// Writes returns the recorded writes in the order they were made.
+func (r *Recorder) Writes() []Write {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Write(nil), r.writes...)
+}
+
+// Summary counts the recorded writes by kind. Writes are sorted by kind and
+// target, so jobs that write concurrently summarize the same way every time.
+func (r *Recorder) Summary() Summary {
+	w := r.Writes()
+	sort.SliceStable(w, func(i, j int) bool {
+		if w[i].Kind != w[j].Kind {
+			return w[i].Kind < w[j].Kind
+		}
+		return w[i].Target < w[j].Target
+	})
+
+	s := Summary{Total: len(w), Writes: w}
+	for _, v := range w {
+		if s.ByKind == nil {
+			s.ByKind = map[string]int{}
+		}
+		s.ByKind[v.Kind]++
+	}
+
+	return s
+}
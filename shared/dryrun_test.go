@@ -0,0 +1,49 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	gql "github.com/icco/graphql"
+)
+
+func TestGraphQLDryRun(t *testing.T) {
+	c := testGraphQL(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Query string }
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.HasPrefix(strings.TrimSpace(req.Query), "mutation") {
+			t.Errorf("mutation sent in a dry run: %s", req.Query)
+		}
+		w.Write([]byte(`{"data": {"homeTimelineURLs": []}}`))
+	})
+	rec := &Recorder{}
+	c.DryRun = rec
+
+	ctx := context.Background()
+	if err := c.Do(ctx, `query { homeTimelineURLs { tweetIDs } }`, nil, nil); err != nil {
+		t.Fatalf("queries should still be sent: %v", err)
+	}
+	if err := c.UpsertStat(ctx, gql.NewStat{Key: "k", Value: 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	id := "13079982"
+	if err := c.Batch(ctx, []Upsert{BookUpsert(gql.EditBook{ID: &id}), StatUpsert(gql.NewStat{Key: "j", Value: 2})}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := rec.Summary()
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"total":3,"by_kind":{"graphql":3},"writes":[` +
+		`{"kind":"graphql","target":"upsertBook","payload":{"id":"13079982","goodreads_id":""}},` +
+		`{"kind":"graphql","target":"upsertStat","payload":{"key":"k","value":1.5}},` +
+		`{"kind":"graphql","target":"upsertStat","payload":{"key":"j","value":2}}]}`
+	if string(b) != want {
+		t.Errorf("summary = %s\nwant %s", b, want)
+	}
+}
@@ -0,0 +1,309 @@
+package shared
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/icco/cron/retry"
+	"github.com/icco/cron/tracing"
+	gql "github.com/icco/graphql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// GraphQLEndpoint is where our GraphQL API lives, unless GQL_ENDPOINT
+	// says otherwise.
+	GraphQLEndpoint = "https://graphql.natwelch.com/graphql"
+
+	// UserAgent is sent with every request to our own services.
+	UserAgent = "icco-cron/1.0"
+)
+
+// GraphQL is a client for our GraphQL API. It authenticates every request,
+// retries ones that failed for reasons that might go away, and classifies
+// errors for the job's own retries.
+type GraphQL struct {
+	Log *zap.SugaredLogger
+
+	Endpoint  string
+	Token     string
+	UserAgent string
+
+	// Client makes the requests. Nil means http.DefaultClient.
+	Client *http.Client
+
+	// Timeout caps each attempt at a request.
+	Timeout time.Duration
+
+	// Retry is how failed requests are retried.
+	Retry retry.Policy
+
+	// BatchSize is the most upserts Batch sends in one request.
+	BatchSize int
+
+	// Outbox, if set, is where mutations that still fail after retrying go
+	// to be sent later, unless the API rejected them.
+	Outbox Queue
+
+	// DryRun, if set, records mutations instead of sending them. Queries are
+	// still sent.
+	DryRun *Recorder
+}
+
+// Queue holds GraphQL requests to send once the API is back.
+type Queue interface {
+	Enqueue(ctx context.Context, query string, vars map[string]any) error
+}
+
+// errQueued means a mutation was put in the outbox instead of being sent.
+var errQueued = errors.New("graphql: queued in outbox")
+
+// NewGraphQL creates a client that authenticates with token and queues
+// mutations in cfg.Outbox when the API is down. In a dry run mutations go to
+// cfg.DryRun instead.
+func NewGraphQL(cfg Config, token string) *GraphQL {
+	endpoint := GraphQLEndpoint
+	if e := os.Getenv("GQL_ENDPOINT"); e != "" {
+		endpoint = e
+	}
+
+	size := DefaultBatchSize
+	if n, err := strconv.Atoi(os.Getenv("GQL_BATCH_SIZE")); err == nil && n > 0 {
+		size = n
+	}
+
+	return &GraphQL{
+		Log:       cfg.Log,
+		Endpoint:  endpoint,
+		Token:     token,
+		UserAgent: UserAgent,
+		Client:    cfg.HTTPClient(),
+		Timeout:   30 * time.Second,
+		Retry: retry.Policy{
+			MaxAttempts: 3,
+			Initial:     500 * time.Millisecond,
+			Max:         10 * time.Second,
+			Multiplier:  2,
+			Jitter:      0.2,
+		},
+		BatchSize: size,
+		Outbox:    cfg.Outbox,
+		DryRun:    cfg.DryRun,
+	}
+}
+
+// GraphQLError is an error the API returned for part of a request.
+type GraphQLError struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// ResponseError holds the errors in a GraphQL response. The API rejecting a
+// request is not something retrying fixes, so it is always permanent.
+type ResponseError struct {
+	Errors []GraphQLError
+}
+
+func (e *ResponseError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, ge := range e.Errors {
+		msgs[i] = ge.Message
+	}
+
+	return "graphql: " + strings.Join(msgs, "; ")
+}
+
+// Do sends query with vars and decodes the data in the response into out,
+// which may be nil.
+func (c *GraphQL) Do(ctx context.Context, query string, vars map[string]any, out any) error {
+	body, err := json.Marshal(struct {
+		Query     string         `json:"query"`
+		Variables map[string]any `json:"variables,omitempty"`
+	}{query, vars})
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("encode graphql request: %w", err))
+	}
+
+	return retry.Do(ctx, c.Retry, func(ctx context.Context, attempt int) error {
+		err := c.do(ctx, body, out)
+		if err != nil && c.Log != nil {
+			c.Log.Debugw("graphql request failed", "attempt", attempt, "class", retry.ClassOf(err).String(), zap.Error(err))
+		}
+
+		return err
+	})
+}
+
+func (c *GraphQL) do(ctx context.Context, body []byte, out any) error {
+	rctx := ctx
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		rctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(rctx, http.MethodPost, c.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("create graphql request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.UserAgent)
+	if c.Token != "" {
+		req.Header.Set("X-API-AUTH", c.Token)
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+
+		// Our own timeout, or a dropped connection.
+		return retry.Transient(fmt.Errorf("graphql: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("graphql: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if s, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil {
+				return retry.RateLimited(err, time.Now().Add(time.Duration(s)*time.Second))
+			}
+		}
+
+		return retry.FromStatus(resp.StatusCode, err)
+	}
+
+	var r struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []GraphQLError  `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("decode graphql response: %w", err)
+	}
+
+	if len(r.Errors) > 0 {
+		return retry.Permanent(&ResponseError{Errors: r.Errors})
+	}
+
+	if out != nil && len(r.Data) > 0 {
+		if err := json.Unmarshal(r.Data, out); err != nil {
+			return retry.Permanent(fmt.Errorf("decode graphql data: %w", err))
+		}
+	}
+
+	return nil
+}
+
+// mutate runs a single mutation in its own span.
+func (c *GraphQL) mutate(ctx context.Context, name, query string, vars map[string]any, attrs ...attribute.KeyValue) error {
+	ctx, span := tracing.Start(ctx, "graphql "+name, attrs...)
+	defer span.End()
+
+	if c.DryRun != nil {
+		// Each mutation takes a single input.
+		for _, v := range vars {
+			c.DryRun.Record(WriteGraphQL, name, v)
+		}
+		span.SetAttributes(attribute.Bool("graphql.dry_run", true))
+		return nil
+	}
+
+	err := c.queue(ctx, query, vars, c.Do(ctx, query, vars, nil))
+	if errors.Is(err, errQueued) {
+		span.SetAttributes(attribute.Bool("graphql.queued", true))
+		return nil
+	}
+
+	return tracing.Error(span, err)
+}
+
+// queue puts a mutation that failed with err in the outbox, unless there is
+// none, the API rejected it or ctx is done. It returns errQueued if it did.
+func (c *GraphQL) queue(ctx context.Context, query string, vars map[string]any, err error) error {
+	if !c.canQueue(ctx, err) {
+		return err
+	}
+
+	if qerr := c.Outbox.Enqueue(ctx, query, vars); qerr != nil {
+		return fmt.Errorf("%w (could not queue it: %v)", err, qerr)
+	}
+	if c.Log != nil {
+		c.Log.Warnw("queued graphql mutation for later", zap.Error(err))
+	}
+
+	return errQueued
+}
+
+// canQueue says whether a mutation that failed with err can go in the outbox.
+func (c *GraphQL) canQueue(ctx context.Context, err error) bool {
+	return err != nil && c.Outbox != nil && ctx.Err() == nil && retry.ClassOf(err) != retry.ClassPermanent
+}
+
+const (
+	upsertStatMutation = `mutation ($s: NewStat!) {
+  upsertStat(input: $s) {
+    when
+  }
+}`
+
+	upsertBookMutation = `mutation ($b: EditBook!) {
+  upsertBook(input: $b) {
+    id
+  }
+}`
+
+	upsertLinkMutation = `mutation ($l: NewLink!) {
+  upsertLink(input: $l) {
+    id
+  }
+}`
+
+	upsertTweetMutation = `mutation ($t: NewTweet!) {
+  upsertTweet(input: $t) {
+    id
+  }
+}`
+)
+
+// UpsertStat saves a stat.
+func (c *GraphQL) UpsertStat(ctx context.Context, s gql.NewStat) error {
+	return c.mutate(ctx, "upsertStat", upsertStatMutation, map[string]any{"s": s}, attribute.String("stat.key", s.Key))
+}
+
+// UpsertBook saves a book.
+func (c *GraphQL) UpsertBook(ctx context.Context, b gql.EditBook) error {
+	var id string
+	if b.ID != nil {
+		id = *b.ID
+	}
+
+	return c.mutate(ctx, "upsertBook", upsertBookMutation, map[string]any{"b": b}, attribute.String("book.id", id))
+}
+
+// UpsertLink saves a link.
+func (c *GraphQL) UpsertLink(ctx context.Context, l gql.NewLink) error {
+	return c.mutate(ctx, "upsertLink", upsertLinkMutation, map[string]any{"l": l}, attribute.String("link.uri", l.URI.String()))
+}
+
+// UpsertTweet saves a tweet.
+func (c *GraphQL) UpsertTweet(ctx context.Context, t gql.NewTweet) error {
+	return c.mutate(ctx, "upsertTweet", upsertTweetMutation, map[string]any{"t": t}, attribute.String("tweet.id", t.ID))
+}
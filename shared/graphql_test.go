@@ -0,0 +1,190 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/icco/cron/retry"
+	gql "github.com/icco/graphql"
+)
+
+func testGraphQL(t *testing.T, h http.HandlerFunc) *GraphQL {
+	t.Helper()
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	c := NewGraphQL(Config{}, "secret")
+	c.Endpoint = srv.URL
+	c.Retry.Initial = time.Millisecond
+	return c
+}
+
+func TestGraphQLDo(t *testing.T) {
+	c := testGraphQL(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-API-AUTH"); got != "secret" {
+			t.Errorf("X-API-AUTH = %q", got)
+		}
+		if got := r.Header.Get("User-Agent"); got != UserAgent {
+			t.Errorf("User-Agent = %q", got)
+		}
+
+		var req struct {
+			Query     string
+			Variables map[string]json.RawMessage
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		if got := string(req.Variables["s"]); got != `{"key":"k","value":1.5}` {
+			t.Errorf("variables = %s", got)
+		}
+
+		w.Write([]byte(`{"data": {"upsertStat": {"when": "2024-01-01T00:00:00Z"}}}`))
+	})
+
+	if err := c.UpsertStat(context.Background(), gql.NewStat{Key: "k", Value: 1.5}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		class    retry.Class
+		attempts int32
+	}{
+		{"unavailable", http.StatusServiceUnavailable, "down", retry.ClassRetryable, 3},
+		{"unauthorized", http.StatusUnauthorized, "no", retry.ClassPermanent, 1},
+		{"graphql errors", http.StatusOK, `{"errors": [{"message": "bad input", "path": ["upsertStat"]}]}`, retry.ClassPermanent, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			c := testGraphQL(t, func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			})
+
+			err := c.Do(context.Background(), `query { counts { key } }`, nil, nil)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := retry.ClassOf(err); got != tc.class {
+				t.Errorf("class = %s, want %s", got, tc.class)
+			}
+			if got := calls.Load(); got != tc.attempts {
+				t.Errorf("attempts = %d, want %d", got, tc.attempts)
+			}
+		})
+	}
+}
+
+func TestGraphQLTimeout(t *testing.T) {
+	var calls atomic.Int32
+	c := testGraphQL(t, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		w.Write([]byte(`{"data": {"counts": [{"key": "a", "value": 2}]}}`))
+	})
+	c.Timeout = 20 * time.Millisecond
+
+	var out struct{ Counts []gql.Stat }
+	if err := c.Do(context.Background(), `query { counts { key, value } }`, nil, &out); err != nil {
+		t.Fatalf("expected a slow first attempt to be retried, got %+v", err)
+	}
+	if len(out.Counts) != 1 || out.Counts[0].Value != 2 {
+		t.Errorf("decoded %+v", out)
+	}
+}
+
+func TestResponseError(t *testing.T) {
+	err := retry.Permanent(&ResponseError{Errors: []GraphQLError{{Message: "a"}, {Message: "b"}}})
+
+	var re *ResponseError
+	if !errors.As(err, &re) || err.Error() != "graphql: a; b" {
+		t.Errorf("got %v", err)
+	}
+}
+
+type fakeQueue struct {
+	queries []string
+}
+
+func (q *fakeQueue) Enqueue(_ context.Context, query string, _ map[string]any) error {
+	q.queries = append(q.queries, query)
+	return nil
+}
+
+func TestGraphQLOutbox(t *testing.T) {
+	var calls atomic.Int32
+	var down atomic.Bool
+	down.Store(true)
+	c := testGraphQL(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if down.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"errors": [{"message": "bad input"}]}`))
+	})
+	c.Retry = retry.Never
+	c.BatchSize = 2
+	q := &fakeQueue{}
+	c.Outbox = q
+
+	if err := c.UpsertStat(context.Background(), gql.NewStat{Key: "k"}); err != nil {
+		t.Fatalf("expected a mutation to be queued while the API is down, got %v", err)
+	}
+	if len(q.queries) != 1 {
+		t.Fatalf("expected one queued mutation, got %d", len(q.queries))
+	}
+
+	upserts := make([]Upsert, 5)
+	for i := range upserts {
+		upserts[i] = StatUpsert(gql.NewStat{Key: "k"})
+	}
+	calls.Store(0)
+	if err := c.Batch(context.Background(), upserts); err != nil {
+		t.Fatalf("expected the batch to be queued, got %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected one request before queueing the rest, got %d", n)
+	}
+	if len(q.queries) != 6 {
+		t.Errorf("expected each upsert to be queued on its own, got %d queued", len(q.queries)-1)
+	}
+	for _, query := range q.queries[1:] {
+		if strings.Contains(query, "u1:") {
+			t.Errorf("expected one upsert per queued mutation, got %s", query)
+		}
+	}
+
+	// Rejected mutations are not queued, as sending them again won't help.
+	down.Store(false)
+	var re *ResponseError
+	if err := c.UpsertStat(context.Background(), gql.NewStat{Key: "k"}); !errors.As(err, &re) {
+		t.Errorf("expected a ResponseError, got %v", err)
+	}
+	if len(q.queries) != 6 {
+		t.Errorf("expected rejected mutation not to be queued")
+	}
+}
@@ -0,0 +1,203 @@
+package shared
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// HTTPHook is told about every request a client from HTTP makes, after it
+// finishes.
+type HTTPHook func(req *http.Request, resp *http.Response, err error, took time.Duration)
+
+// HTTP makes the clients jobs use to talk to other services. Requests from
+// them time out, are rate limited per host and carry our User-Agent.
+type HTTP struct {
+	// Timeout caps each request, including reading the response body.
+	Timeout time.Duration
+
+	UserAgent string
+
+	// Limits is the most requests per second sent to each host. Hosts that
+	// aren't listed get DefaultLimit, and zero means no limit.
+	Limits       map[string]rate.Limit
+	DefaultLimit rate.Limit
+
+	Hooks []HTTPHook
+
+	// Base sends the requests. Nil means http.DefaultTransport.
+	Base http.RoundTripper
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+
+	// others holds the limiters of hosts that aren't in Limits, and recent
+	// orders them from most to least recently used.
+	others map[string]*list.Element
+	recent *list.List
+}
+
+// maxLimiters is how many hosts without a limit of their own in Limits have
+// their limiter kept.
+const maxLimiters = 256
+
+type hostLimiter struct {
+	host string
+	l    *rate.Limiter
+}
+
+// defaultHTTP is used by configs without an HTTP set, which is mostly tests.
+var defaultHTTP = &HTTP{Timeout: 30 * time.Second, UserAgent: UserAgent}
+
+// NewHTTP creates a factory that logs every request at debug level. The
+// timeout is read from HTTP_TIMEOUT (default 30s) and per host limits from
+// HTTP_RATE_LIMITS, like "api.twitter.com=1,*=10", where "*" is every other
+// host (default 10 a second).
+func NewHTTP(log *zap.SugaredLogger, hooks ...HTTPHook) (*HTTP, error) {
+	h := &HTTP{
+		Timeout:      30 * time.Second,
+		UserAgent:    UserAgent,
+		Limits:       map[string]rate.Limit{},
+		DefaultLimit: 10,
+		Hooks:        append([]HTTPHook{LogHTTP(log)}, hooks...),
+	}
+
+	if s := os.Getenv("HTTP_TIMEOUT"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("parse HTTP_TIMEOUT: %w", err)
+		}
+		h.Timeout = d
+	}
+
+	if s := os.Getenv("HTTP_RATE_LIMITS"); s != "" {
+		for _, kv := range strings.Split(s, ",") {
+			host, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+			n, err := strconv.ParseFloat(v, 64)
+			if !ok || err != nil || n < 0 {
+				return nil, fmt.Errorf("parse HTTP_RATE_LIMITS: bad limit %q", kv)
+			}
+
+			if host == "*" {
+				h.DefaultLimit = rate.Limit(n)
+			} else {
+				h.Limits[host] = rate.Limit(n)
+			}
+		}
+	}
+
+	return h, nil
+}
+
+// LogHTTP is a hook that logs each request at debug level.
+func LogHTTP(log *zap.SugaredLogger) HTTPHook {
+	return func(req *http.Request, resp *http.Response, err error, took time.Duration) {
+		if err != nil {
+			log.Debugw("http request failed", "method", req.Method, "host", req.URL.Host, "path", req.URL.Path, "took", took, zap.Error(err))
+			return
+		}
+		log.Debugw("http request", "method", req.Method, "host", req.URL.Host, "path", req.URL.Path, "status", resp.StatusCode, "took", took)
+	}
+}
+
+// Client returns a client for making requests. Clients share rate limits.
+func (h *HTTP) Client() *http.Client {
+	return &http.Client{Timeout: h.Timeout, Transport: h.Transport()}
+}
+
+// Transport returns a RoundTripper that applies everything but the timeout,
+// for libraries that bring their own client but take a transport.
+func (h *HTTP) Transport() http.RoundTripper {
+	return &httpTransport{h: h}
+}
+
+// limiter returns the limiter for host, or nil if it has no limit. Hosts in
+// Limits keep their limiter, but only the last maxLimiters other hosts do, so
+// the spider crawling the web doesn't fill memory with them.
+func (h *HTTP) limiter(host string) *rate.Limiter {
+	limit, listed := h.Limits[host]
+	if !listed {
+		limit = h.DefaultLimit
+	}
+	if limit <= 0 || limit == rate.Inf {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if listed {
+		if h.limiters == nil {
+			h.limiters = map[string]*rate.Limiter{}
+		}
+		l, ok := h.limiters[host]
+		if !ok {
+			l = newLimiter(limit)
+			h.limiters[host] = l
+		}
+		return l
+	}
+
+	if h.others == nil {
+		h.others = map[string]*list.Element{}
+		h.recent = list.New()
+	}
+	if e, ok := h.others[host]; ok {
+		h.recent.MoveToFront(e)
+		return e.Value.(*hostLimiter).l
+	}
+
+	l := newLimiter(limit)
+	h.others[host] = h.recent.PushFront(&hostLimiter{host: host, l: l})
+	if h.recent.Len() > maxLimiters {
+		oldest := h.recent.Remove(h.recent.Back()).(*hostLimiter)
+		delete(h.others, oldest.host)
+	}
+
+	return l
+}
+
+func newLimiter(limit rate.Limit) *rate.Limiter {
+	return rate.NewLimiter(limit, int(math.Max(1, math.Ceil(float64(limit)))))
+}
+
+type httpTransport struct {
+	h *HTTP
+}
+
+func (t *httpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if l := t.h.limiter(req.URL.Hostname()); l != nil {
+		if err := l.Wait(req.Context()); err != nil {
+			return nil, fmt.Errorf("wait for rate limit on %s: %w", req.URL.Hostname(), err)
+		}
+	}
+
+	// RoundTrippers must not change the request they are given.
+	if t.h.UserAgent != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.h.UserAgent)
+	}
+
+	base := t.h.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	took := time.Since(start)
+	for _, hook := range t.h.Hooks {
+		hook(req, resp, err, took)
+	}
+
+	return resp, err
+}
@@ -0,0 +1,129 @@
+package shared
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("User-Agent"); got != UserAgent {
+			t.Errorf("User-Agent = %q", got)
+		}
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	var statuses []int
+	h, err := NewHTTP(zap.NewNop().Sugar(), func(_ *http.Request, resp *http.Response, err error, _ time.Duration) {
+		if err != nil {
+			statuses = append(statuses, 0)
+			return
+		}
+		statuses = append(statuses, resp.StatusCode)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Timeout = 50 * time.Millisecond
+	h.Limits["127.0.0.1"] = 20
+
+	c := h.Client()
+	start := time.Now()
+	for range 3 {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("User-Agent", "Go-http-client/1.1")
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	// The limit allows a burst of 20, so none of these wait.
+	if time.Since(start) > time.Second {
+		t.Errorf("requests under the limit took %s", time.Since(start))
+	}
+
+	if _, err := c.Get(srv.URL + "/slow"); err == nil {
+		t.Error("expected a request slower than the timeout to fail")
+	}
+
+	if len(statuses) != 4 || statuses[0] != http.StatusOK || statuses[3] != 0 {
+		t.Errorf("hooks saw %v, want three 200s and a failure", statuses)
+	}
+}
+
+func TestHTTPRateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	h := &HTTP{Limits: map[string]rate.Limit{"127.0.0.1": 20}}
+	c := h.Client()
+
+	start := time.Now()
+	for range 22 {
+		resp, err := c.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// The first 20 go as a burst and the next two wait 50ms each.
+	if took := time.Since(start); took < 90*time.Millisecond {
+		t.Errorf("22 requests at 20/s took %s, want at least 100ms", took)
+	}
+
+	if (&HTTP{}).limiter("example.com") != nil {
+		t.Error("expected hosts without a limit to be unlimited")
+	}
+}
+
+func TestHTTPLimiters(t *testing.T) {
+	h := &HTTP{Limits: map[string]rate.Limit{"api.twitter.com": 1}, DefaultLimit: 10}
+
+	twitter := h.limiter("api.twitter.com")
+	first := h.limiter("site0.example.com")
+	for i := range maxLimiters + 10 {
+		h.limiter(fmt.Sprintf("site%d.example.com", i))
+	}
+
+	if len(h.others) != maxLimiters || h.recent.Len() != maxLimiters {
+		t.Errorf("kept %d limiters for other hosts, want %d", len(h.others), maxLimiters)
+	}
+	if h.limiter("site0.example.com") == first {
+		t.Error("expected the least recently used host to be evicted")
+	}
+	if h.limiter("api.twitter.com") != twitter {
+		t.Error("expected listed hosts to keep their limiter")
+	}
+	if got := h.limiter("api.twitter.com").Limit(); got != 1 {
+		t.Errorf("twitter limit = %v, want 1", got)
+	}
+}
+
+func TestNewHTTPEnv(t *testing.T) {
+	t.Setenv("HTTP_TIMEOUT", "5s")
+	t.Setenv("HTTP_RATE_LIMITS", "api.twitter.com=0.5, *=2")
+
+	h, err := NewHTTP(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Timeout != 5*time.Second || h.Limits["api.twitter.com"] != 0.5 || h.DefaultLimit != 2 {
+		t.Errorf("got timeout %s, limits %v, default %v", h.Timeout, h.Limits, h.DefaultLimit)
+	}
+
+	t.Setenv("HTTP_RATE_LIMITS", "api.twitter.com")
+	if _, err := NewHTTP(zap.NewNop().Sugar()); err == nil {
+		t.Error("expected a limit without a rate to be rejected")
+	}
+}
@@ -250,3 +250,14 @@ var All = []SiteMap{
 		Branch:     "main",
 	},
 }
+
+// Find returns the site with the given deployment name.
+func Find(deployment string) (SiteMap, bool) {
+	for _, s := range All {
+		if s.Deployment == deployment {
+			return s, true
+		}
+	}
+
+	return SiteMap{}, false
+}
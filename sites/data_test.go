@@ -0,0 +1,23 @@
+package sites
+
+import "testing"
+
+func TestFind(t *testing.T) {
+	for _, tc := range []struct {
+		deployment string
+		want       bool
+	}{
+		{"gotak", true},
+		{"writing", true},
+		{"nope", false},
+		{"", false},
+	} {
+		s, ok := Find(tc.deployment)
+		if ok != tc.want {
+			t.Errorf("Find(%q) found %v, want %v", tc.deployment, ok, tc.want)
+		}
+		if ok && s.Deployment != tc.deployment {
+			t.Errorf("Find(%q) = %+v", tc.deployment, s)
+		}
+	}
+}
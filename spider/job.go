@@ -0,0 +1,31 @@
+package spider
+
+import (
+	"context"
+
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/retry"
+)
+
+func init() {
+	jobs.Register(jobs.New(
+		"spider",
+		"Crawls a site, writing.natwelch.com by default, for thirty seconds.",
+		func(ctx context.Context, cfg *jobs.Config) error {
+			u := cfg.Args["url"]
+			if u == "" {
+				u = "https://writing.natwelch.com/"
+			}
+
+			Crawl(ctx, &Config{
+				Config: cfg.Config,
+				URL:    u,
+			})
+
+			return nil
+		},
+		jobs.WithArgs(jobs.Arg{Name: "url", Description: "URL to start crawling from.", Validate: jobs.ValidateURL}),
+		jobs.WithConcurrency(jobs.Forbid, 0),
+		jobs.WithRetry(retry.Never),
+	))
+}
@@ -52,10 +52,14 @@ func enqueue(ctx context.Context, uri string, queue chan string) {
 	atomic.AddUint64(&ops, 1)
 	c.Log.Infow("enqued", "ops", atomic.LoadUint64(&ops), "uri", uri)
 
-	client := http.DefaultClient
-
-	resp, err := client.Get(uri)
 	visited[uri] = true
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		c.Log.Infow("error building request", zap.Error(err))
+		return
+	}
+
+	resp, err := c.HTTPClient().Do(req)
 	if err != nil {
 		c.Log.Infow("error scrapping", zap.Error(err))
 		return
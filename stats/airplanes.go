@@ -63,11 +63,11 @@ func GetAirplanes(ctx context.Context, cfg *Config) (float64, error) {
 		return 0.0, fmt.Errorf("build request: %w", err)
 	}
 
-	client := http.DefaultClient
-	resp, err := client.Do(req)
+	resp, err := cfg.HTTPClient().Do(req)
 	if err != nil {
 		return 0.0, fmt.Errorf("do request: %w", err)
 	}
+	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -1,6 +1,9 @@
 package stats
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestUnmarshalAirplanes(t *testing.T) {
 	tests := []string{
@@ -14,3 +17,15 @@ func TestUnmarshalAirplanes(t *testing.T) {
 		}
 	}
 }
+
+func TestGetAirplanes(t *testing.T) {
+	cfg := replayConfig(t, "testdata/aircraft.replay.json")
+
+	got, err := GetAirplanes(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 3.0; got != want {
+		t.Errorf("airplanes = %v, want %v", got, want)
+	}
+}
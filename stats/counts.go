@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	gql "github.com/icco/graphql"
-	"github.com/machinebox/graphql"
 )
 
 type countsResponse struct {
@@ -14,14 +13,8 @@ type countsResponse struct {
 
 // GetCounts gets counts from graphql.
 func GetCounts(ctx context.Context, cfg *Config) ([]*gql.Stat, error) {
-	gqlClient := graphql.NewClient("https://graphql.natwelch.com/graphql")
-	gqlClient.Log = func(s string) { cfg.Log.Debug(s) }
-
-	req := graphql.NewRequest(`query { counts { key, value } }`)
-	req.Header.Add("X-API-AUTH", cfg.GraphQLToken)
-	req.Header.Add("User-Agent", "icco-cron/1.0")
 	var resp countsResponse
-	if err := gqlClient.Run(ctx, req, &resp); err != nil {
+	if err := cfg.GraphQL.Do(ctx, `query { counts { key, value } }`, nil, &resp); err != nil {
 		return nil, err
 	}
 
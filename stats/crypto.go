@@ -18,32 +18,32 @@ type coinbaseResponse struct {
 
 // GetChiaPrice gets the price of XCH in USD.
 func GetChiaPrice(ctx context.Context, cfg *Config) (float64, error) {
-	return GetCryptoPrice(ctx, "XCH")
+	return GetCryptoPrice(ctx, cfg, "XCH")
 }
 
 // GetETHPrice gets the price of eth in USD.
 func GetETHPrice(ctx context.Context, cfg *Config) (float64, error) {
-	return GetCryptoPrice(ctx, "ETH")
+	return GetCryptoPrice(ctx, cfg, "ETH")
 }
 
 // GetBTCPrice gets the price of BTC in USD.
 func GetBTCPrice(ctx context.Context, cfg *Config) (float64, error) {
-	return GetCryptoPrice(ctx, "BTC")
+	return GetCryptoPrice(ctx, cfg, "BTC")
 }
 
 // GetCryptoPrice gets a crypto in USD from coinbase.
-func GetCryptoPrice(ctx context.Context, crypto string) (float64, error) {
+func GetCryptoPrice(ctx context.Context, cfg *Config, crypto string) (float64, error) {
 	url := fmt.Sprintf("https://api.coinbase.com/v2/exchange-rates?currency=%s", crypto)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return 0.0, fmt.Errorf("build request: %w", err)
 	}
 
-	client := http.DefaultClient
-	resp, err := client.Do(req)
+	resp, err := cfg.HTTPClient().Do(req)
 	if err != nil {
 		return 0.0, fmt.Errorf("do request: %w", err)
 	}
+	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -0,0 +1,47 @@
+package stats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/icco/cron/replay"
+	"github.com/icco/cron/shared"
+	"go.uber.org/zap"
+)
+
+// replayConfig returns a Config whose requests are replayed from the fixture
+// at path.
+func replayConfig(t *testing.T, path string, secrets ...string) *Config {
+	t.Helper()
+
+	return &Config{Config: shared.Config{
+		Log:  zap.NewNop().Sugar(),
+		HTTP: &shared.HTTP{Base: replay.New(t, path, secrets...)},
+	}}
+}
+
+func TestGetCryptoPrice(t *testing.T) {
+	cfg := replayConfig(t, "testdata/coinbase.replay.json")
+
+	tests := []struct {
+		name string
+		get  KeyFunc
+		want float64
+	}{
+		{"BTC", GetBTCPrice, 67234.515},
+		{"ETH", GetETHPrice, 3512.275},
+		{"XCH", GetChiaPrice, 24.12},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.get(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("price = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package stats
+
+import (
+	"context"
+
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/shared"
+)
+
+func init() {
+	jobs.Register(jobs.New(
+		"stats",
+		"Fetches quick stats like prices and weather and uploads them to graphql.",
+		func(ctx context.Context, cfg *jobs.Config) error {
+			c := &Config{
+				Config:  cfg.Config,
+				GraphQL: shared.NewGraphQL(cfg.Config, cfg.Secret("GQL_TOKEN")),
+				OWMKey:  cfg.Secret("OPEN_WEATHER_MAP_KEY"),
+			}
+
+			return c.UpdateOften(ctx)
+		},
+		jobs.WithSecrets("GQL_TOKEN", "OPEN_WEATHER_MAP_KEY"),
+		jobs.WithConcurrency(jobs.Forbid, 0),
+		jobs.WithSchedule("*/5 * * * *"),
+	))
+
+	jobs.Register(jobs.New(
+		"test",
+		"Logs our asset mix from LunchMoney.",
+		func(ctx context.Context, cfg *jobs.Config) error {
+			c := &Config{
+				Config:          cfg.Config,
+				LunchMoneyToken: cfg.Secret("LUNCHMONEY_TOKEN"),
+			}
+
+			v, err := GetAssetMix(ctx, c)
+			if err != nil {
+				return err
+			}
+			cfg.Log.Warnf("%v, %+v", v, err)
+
+			return nil
+		},
+		jobs.WithSecrets("LUNCHMONEY_TOKEN"),
+	))
+}
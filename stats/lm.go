@@ -4,19 +4,20 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
+	"net/http"
 
 	"github.com/icco/lunchmoney"
 )
 
 // GetAssetMix gets our asset mix from LunchMoney.
-// TODO: Add to config thing.
-func GetAssetMix(ctx context.Context) (float64, error) {
-	token := os.Getenv("LUNCHMONEY_TOKEN")
-	client, err := lunchmoney.NewClient(token)
+func GetAssetMix(ctx context.Context, cfg *Config) (float64, error) {
+	client, err := lunchmoney.NewClient(cfg.LunchMoneyToken)
 	if err != nil {
 		return 0.0, fmt.Errorf("lm client: %w", err)
 	}
+	hc := cfg.HTTPClient()
+	hc.Transport = &bearerTransport{token: cfg.LunchMoneyToken, base: hc.Transport}
+	client.HTTP = hc
 
 	as, err := client.GetAssets(ctx)
 	if err != nil {
@@ -48,3 +49,17 @@ func GetAssetMix(ctx context.Context) (float64, error) {
 
 	return 0.0, nil
 }
+
+// bearerTransport authenticates requests to LunchMoney, which the client's
+// own transport did before we replaced it.
+type bearerTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+
+	return t.base.RoundTrip(req)
+}
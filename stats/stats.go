@@ -6,7 +6,6 @@ import (
 
 	"github.com/icco/cron/shared"
 	gql "github.com/icco/graphql"
-	"github.com/machinebox/graphql"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -14,8 +13,9 @@ import (
 type Config struct {
 	shared.Config
 
-	GraphQLToken string
-	OWMKey       string
+	GraphQL         *shared.GraphQL
+	OWMKey          string
+	LunchMoneyToken string
 }
 
 // KeyFunc is a function for key exporters.
@@ -86,24 +86,6 @@ func (c *Config) UploadStat(ctx context.Context, key string, value float64) erro
 		Value: value,
 	}
 
-	gqlClient := graphql.NewClient("https://graphql.natwelch.com/graphql")
-	mut := `
-  mutation ($s: NewStat!) {
-      upsertStat(input: $s) {
-        when
-      }
-    }
-  `
-
-	req := graphql.NewRequest(mut)
-	req.Var("s", s)
-	req.Header.Add("X-API-AUTH", c.GraphQLToken)
-	req.Header.Add("User-Agent", "icco-cron/1.0")
-
 	c.Log.Debugw("uploading stat", "stat", s)
-	if err := gqlClient.Run(ctx, req, nil); err != nil {
-		return fmt.Errorf("graphql: %w", err)
-	}
-
-	return nil
+	return c.GraphQL.UpsertStat(ctx, s)
 }
@@ -0,0 +1,67 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/icco/cron/gqltest"
+	"github.com/icco/cron/shared"
+	"go.uber.org/zap"
+)
+
+// sorted orders mutations by input, since stats are uploaded concurrently.
+func sorted(m []gqltest.Mutation) []gqltest.Mutation {
+	sort.Slice(m, func(i, j int) bool { return string(m[i].Input) < string(m[j].Input) })
+	return m
+}
+
+func TestUpdateOften(t *testing.T) {
+	srv := gqltest.NewServer(t)
+	srv.Upstream("newyork.welch.io", gqltest.File(t, "testdata/aircraft.json"))
+
+	prices := map[string]string{"BTC": "42000.5", "ETH": "2250.25", "XCH": "31.1"}
+	srv.Upstream("api.coinbase.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := r.URL.Query().Get("currency")
+		fmt.Fprintf(w, `{"data": {"currency": %q, "rates": {"USD": %q}}}`, c, prices[c])
+	}))
+
+	temps := map[string]float64{
+		"Beacon, NY, US":     31.5,
+		"Chester, CA, US":    40.2,
+		"London, UK":         45,
+		"Santa Rosa, CA, US": 58.8,
+		"Seattle, WA, US":    47.3,
+	}
+	srv.Upstream("api.openweathermap.org", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		json.NewEncoder(w).Encode(map[string]any{"name": q, "main": map[string]float64{"temp": temps[q]}})
+	}))
+
+	if err := srv.Run(t, "stats", map[string]string{"OPEN_WEATHER_MAP_KEY": "key"}); err != nil {
+		t.Fatal(err)
+	}
+
+	gqltest.Golden(t, "testdata/stats.golden.json", sorted(srv.Mutations()))
+}
+
+func TestUpdateRarely(t *testing.T) {
+	srv := gqltest.NewServer(t)
+	srv.Respond("counts", []map[string]any{
+		{"key": "Posts", "value": 1024},
+		{"key": "Links", "value": 9000},
+	})
+
+	c := &Config{
+		Config:  shared.Config{Log: zap.NewNop().Sugar()},
+		GraphQL: shared.NewGraphQL(shared.Config{}, "token"),
+	}
+	if err := c.UpdateRarely(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	gqltest.Golden(t, "testdata/counts.golden.json", srv.Mutations())
+}
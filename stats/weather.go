@@ -20,7 +20,7 @@ func GetCurrentWeather(location string) KeyFunc {
 			APIKey: cfg.OWMKey,
 		}
 
-		w, err := openweathermap.NewCurrent(wc.Unit, wc.Lang, wc.APIKey)
+		w, err := openweathermap.NewCurrent(wc.Unit, wc.Lang, wc.APIKey, openweathermap.WithHttpClient(cfg.HTTPClient()))
 		if err != nil {
 			return 0.0, err
 		}
@@ -0,0 +1,22 @@
+package stats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/icco/cron/replay"
+)
+
+func TestGetCurrentWeather(t *testing.T) {
+	key := replay.Secret(t, "OPEN_WEATHER_MAP_KEY", "0123456789abcdef0123456789abcdef")
+	cfg := replayConfig(t, "testdata/openweathermap.replay.json", key)
+	cfg.OWMKey = key
+
+	got, err := GetCurrentWeather("Seattle, WA, US")(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 52.7; got != want {
+		t.Errorf("temperature = %v, want %v", got, want)
+	}
+}
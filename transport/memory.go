@@ -0,0 +1,118 @@
+package transport
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultRedeliveryDelay is how long Memory waits before delivering a nacked
+// message again, unless RedeliveryDelay says otherwise.
+const DefaultRedeliveryDelay = 10 * time.Second
+
+// Memory is a queue of messages in memory, for tests and local development.
+// Messages are delivered concurrently, and nacked ones go to the back of the
+// queue after a delay, like Pub/Sub does. It is safe for concurrent use.
+type Memory struct {
+	// RedeliveryDelay is how long a nacked message waits before it is
+	// delivered again. Zero means DefaultRedeliveryDelay.
+	RedeliveryDelay time.Duration
+
+	mu      sync.Mutex
+	queue   []*Message
+	pending int
+	lastID  int
+	ready   chan struct{}
+}
+
+// NewMemory returns an empty queue.
+func NewMemory() *Memory {
+	return &Memory{ready: make(chan struct{}, 1)}
+}
+
+// Publish adds a message to the queue and returns its ID.
+func (m *Memory) Publish(data []byte, attrs map[string]string) string {
+	m.mu.Lock()
+	m.lastID++
+	msg := &Message{ID: strconv.Itoa(m.lastID), Data: data, Attributes: attrs}
+	m.pending++
+	m.mu.Unlock()
+
+	m.push(msg)
+	return msg.ID
+}
+
+// Pending is how many messages have not been acked yet.
+func (m *Memory) Pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.pending
+}
+
+func (m *Memory) push(msg *Message) {
+	m.mu.Lock()
+	m.queue = append(m.queue, msg)
+	m.mu.Unlock()
+
+	select {
+	case m.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Memory) redeliveryDelay() time.Duration {
+	if m.RedeliveryDelay > 0 {
+		return m.RedeliveryDelay
+	}
+
+	return DefaultRedeliveryDelay
+}
+
+func (m *Memory) pop() *Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.queue) == 0 {
+		return nil
+	}
+	msg := m.queue[0]
+	m.queue = m.queue[1:]
+	return msg
+}
+
+// Receive implements Transport.
+func (m *Memory) Receive(ctx context.Context, h Handler) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		msg := m.pop()
+		if msg == nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-m.ready:
+				continue
+			}
+		}
+		if ctx.Err() != nil {
+			m.push(msg)
+			return nil
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if h(ctx, msg) == Nack {
+				time.AfterFunc(m.redeliveryDelay(), func() { m.push(msg) })
+				return
+			}
+			m.mu.Lock()
+			m.pending--
+			m.mu.Unlock()
+		}()
+	}
+}
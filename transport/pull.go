@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Pull receives messages from a Pub/Sub subscription. Acked messages are
+// acked on the subscription and nacked ones are nacked, so Pub/Sub delivers
+// them again.
+type Pull struct {
+	client       *pubsub.Client
+	topic        string
+	subscription string
+}
+
+// NewPull receives from subscription, which is created on topic if it doesn't
+// exist. The Pull takes ownership of client.
+func NewPull(client *pubsub.Client, topic, subscription string) *Pull {
+	return &Pull{client: client, topic: topic, subscription: subscription}
+}
+
+// NewEmulator receives from subscription on the Pub/Sub emulator at host,
+// like localhost:8085. The emulator starts out empty, so topic is created if
+// it doesn't exist as well.
+func NewEmulator(ctx context.Context, host, project, topic, subscription string) (*Pull, error) {
+	client, err := pubsub.NewClient(ctx, project,
+		option.WithEndpoint(host),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create pubsub client: %w", err)
+	}
+
+	t := client.Topic(topic)
+	ok, err := t.Exists(ctx)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("check topic %q: %w", topic, err)
+	}
+	if !ok {
+		if _, err := client.CreateTopic(ctx, topic); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("create topic %q: %w", topic, err)
+		}
+	}
+
+	return NewPull(client, topic, subscription), nil
+}
+
+// Receive implements Transport.
+func (p *Pull) Receive(ctx context.Context, h Handler) error {
+	sub := p.client.Subscription(p.subscription)
+	ok, err := sub.Exists(ctx)
+	if err != nil {
+		return fmt.Errorf("check subscription %q: %w", p.subscription, err)
+	}
+	if !ok {
+		if _, err := p.client.CreateSubscription(ctx, p.subscription, pubsub.SubscriptionConfig{
+			Topic: p.client.Topic(p.topic),
+		}); err != nil {
+			return fmt.Errorf("create subscription %q: %w", p.subscription, err)
+		}
+	}
+
+	err = sub.Receive(ctx, func(ctx context.Context, m *pubsub.Message) {
+		if h(ctx, &Message{ID: m.ID, Data: m.Data, Attributes: m.Attributes}) == Ack {
+			m.Ack()
+			return
+		}
+		m.Nack()
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("receive from %q: %w", p.subscription, err)
+	}
+
+	return nil
+}
+
+// Close closes the Pub/Sub client.
+func (p *Pull) Close() error {
+	return p.client.Close()
+}
@@ -0,0 +1,82 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// pushRequest is the body of a Pub/Sub push request.
+type pushRequest struct {
+	Message struct {
+		Data       []byte            `json:"data,omitempty"`
+		ID         string            `json:"messageId"`
+		Attributes map[string]string `json:"attributes,omitempty"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+// Push receives messages from a Pub/Sub push subscription pointed at it. It
+// answers acked messages with a 204 and nacked ones with a 503, which Pub/Sub
+// delivers again. Requests that come in while it isn't receiving are nacked.
+type Push struct {
+	mu sync.Mutex
+	h  Handler
+	wg sync.WaitGroup
+}
+
+// NewPush returns a Push that isn't receiving yet.
+func NewPush() *Push {
+	return &Push{}
+}
+
+// Receive implements Transport.
+func (p *Push) Receive(ctx context.Context, h Handler) error {
+	p.mu.Lock()
+	p.h = h
+	p.mu.Unlock()
+
+	<-ctx.Done()
+
+	p.mu.Lock()
+	p.h = nil
+	p.mu.Unlock()
+	p.wg.Wait()
+
+	return nil
+}
+
+func (p *Push) handler() Handler {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.h != nil {
+		p.wg.Add(1)
+	}
+	return p.h
+}
+
+// ServeHTTP handles a push request, calling the handler before it responds.
+func (p *Push) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req pushRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "body decode error", http.StatusBadRequest)
+		return
+	}
+
+	h := p.handler()
+	if h == nil {
+		http.Error(w, "not receiving", http.StatusServiceUnavailable)
+		return
+	}
+	defer p.wg.Done()
+
+	msg := &Message{ID: req.Message.ID, Data: req.Message.Data, Attributes: req.Message.Attributes}
+	if h(r.Context(), msg) == Nack {
+		http.Error(w, "nacked", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
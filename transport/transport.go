@@ -0,0 +1,47 @@
+// Package transport delivers cron messages to the server. Messages can come
+// from a Pub/Sub pull subscription, Pub/Sub push requests, the Pub/Sub
+// emulator or an in memory queue, and are acked and nacked the same way
+// whichever it is.
+package transport
+
+import (
+	"context"
+)
+
+// Message is a message as it was published.
+type Message struct {
+	ID         string
+	Data       []byte
+	Attributes map[string]string
+}
+
+// Result is what a Handler decided to do with a message.
+type Result int
+
+const (
+	// Ack means the message was dealt with and should not be delivered
+	// again.
+	Ack Result = iota
+
+	// Nack means the message should be delivered again later.
+	Nack
+)
+
+func (r Result) String() string {
+	if r == Ack {
+		return "ack"
+	}
+
+	return "nack"
+}
+
+// Handler deals with a message. Handlers may be called concurrently.
+type Handler func(ctx context.Context, msg *Message) Result
+
+// Transport delivers messages to a Handler.
+type Transport interface {
+	// Receive calls h with each message until ctx is done, then waits for
+	// calls to h to return. It returns nil once ctx is done, or an error if
+	// messages can't be received.
+	Receive(ctx context.Context, h Handler) error
+}
@@ -0,0 +1,179 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+)
+
+// testTransport checks that tr delivers what publish sends, and delivers
+// nacked messages again.
+func testTransport(t *testing.T, tr Transport, publish func(data string)) {
+	t.Helper()
+
+	var mu sync.Mutex
+	seen := map[string]int{}
+	done := make(chan struct{})
+	h := func(_ context.Context, msg *Message) Result {
+		mu.Lock()
+		defer mu.Unlock()
+
+		data := string(msg.Data)
+		seen[data]++
+		if seen["ack"] == 1 && seen["nack"] == 2 {
+			close(done)
+		}
+		if data == "nack" && seen[data] == 1 {
+			return Nack
+		}
+		return Ack
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() { errs <- tr.Receive(ctx, h) }()
+
+	publish("ack")
+	publish("nack")
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		mu.Lock()
+		defer mu.Unlock()
+		t.Fatalf("expected ack once and nack twice, got %v", seen)
+	}
+
+	cancel()
+	if err := <-errs; err != nil {
+		t.Errorf("expected Receive to stop cleanly, got %v", err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	m.RedeliveryDelay = 10 * time.Millisecond
+	testTransport(t, m, func(data string) { m.Publish([]byte(data), nil) })
+
+	if n := m.Pending(); n != 0 {
+		t.Errorf("expected every message to be acked, %d are pending", n)
+	}
+}
+
+func TestMemoryRedeliveryDelay(t *testing.T) {
+	m := NewMemory()
+	m.RedeliveryDelay = 100 * time.Millisecond
+	m.Publish([]byte("nack"), nil)
+
+	var mu sync.Mutex
+	var delivered []time.Time
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	if err := m.Receive(ctx, func(context.Context, *Message) Result {
+		mu.Lock()
+		defer mu.Unlock()
+
+		delivered = append(delivered, time.Now())
+		return Nack
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(delivered) < 2 || len(delivered) > 5 {
+		t.Fatalf("expected a message nacked every time to be delivered a few times in 250ms, got %d", len(delivered))
+	}
+	if gap := delivered[1].Sub(delivered[0]); gap < m.RedeliveryDelay {
+		t.Errorf("expected redelivery after %s, got %s", m.RedeliveryDelay, gap)
+	}
+	if m.Pending() != 1 {
+		t.Errorf("expected the message to still be pending, got %d", m.Pending())
+	}
+}
+
+func TestPush(t *testing.T) {
+	p := NewPush()
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	// Pub/Sub delivers a message again until it gets a success.
+	publish := func(data string) {
+		go func() {
+			for {
+				var req pushRequest
+				req.Message.ID = data
+				req.Message.Data = []byte(data)
+				b, err := json.Marshal(req)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				resp, err := http.Post(srv.URL, "application/json", bytes.NewReader(b))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				resp.Body.Close()
+				if resp.StatusCode == http.StatusNoContent {
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+	}
+	testTransport(t, p, publish)
+
+	resp, err := http.Post(srv.URL, "application/json", bytes.NewReader([]byte(`{"message":{"data":"e30="}}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected a 503 once Receive returned, got %d", resp.StatusCode)
+	}
+}
+
+func TestEmulator(t *testing.T) {
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	p, err := NewEmulator(ctx, srv.Addr, "test", "cron", "cron-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	topic := p.client.Topic("cron")
+	defer topic.Stop()
+
+	// The subscription is created when receiving starts, and messages
+	// published before that aren't delivered.
+	started := make(chan struct{})
+	go func() {
+		for {
+			if ok, err := p.client.Subscription("cron-client").Exists(ctx); err == nil && ok {
+				close(started)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	testTransport(t, p, func(data string) {
+		<-started
+		if _, err := topic.Publish(ctx, &pubsub.Message{Data: []byte(data)}).Get(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+}
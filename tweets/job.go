@@ -0,0 +1,58 @@
+package tweets
+
+import (
+	"context"
+
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/shared"
+)
+
+var twitterSecrets = []string{
+	"GQL_TOKEN",
+	"TWITTER_CONSUMER_KEY",
+	"TWITTER_CONSUMER_SECRET",
+	"TWITTER_ACCESS_TOKEN",
+	"TWITTER_ACCESS_SECRET",
+}
+
+func init() {
+	jobs.Register(jobs.New(
+		"user-tweets",
+		"Triggers the cacophony cron and uploads our timeline to graphql.",
+		func(ctx context.Context, cfg *jobs.Config) error {
+			t := newTwitter(cfg)
+			if err := t.CacophonyCron(ctx); err != nil {
+				return err
+			}
+
+			return t.SaveUserTweets(ctx)
+		},
+		jobs.WithSecrets(twitterSecrets...),
+		jobs.WithConcurrency(jobs.Forbid, 0),
+		jobs.WithSchedule("0 * * * *"),
+	))
+
+	jobs.Register(jobs.New(
+		"random-tweets",
+		"Fills in data for random tweets graphql knows about.",
+		func(ctx context.Context, cfg *jobs.Config) error {
+			return newTwitter(cfg).CacheRandomTweets(ctx)
+		},
+		jobs.WithSecrets(twitterSecrets...),
+		jobs.WithConcurrency(jobs.Forbid, 0),
+		jobs.WithSchedule("*/15 * * * *"),
+	))
+}
+
+func newTwitter(cfg *jobs.Config) *Twitter {
+	return &Twitter{
+		Config: cfg.Config,
+		TwitterAuth: &TwitterAuth{
+			ConsumerKey:    cfg.Secret("TWITTER_CONSUMER_KEY"),
+			ConsumerSecret: cfg.Secret("TWITTER_CONSUMER_SECRET"),
+			AccessToken:    cfg.Secret("TWITTER_ACCESS_TOKEN"),
+			AccessSecret:   cfg.Secret("TWITTER_ACCESS_SECRET"),
+		},
+		GraphQL: shared.NewGraphQL(cfg.Config, cfg.Secret("GQL_TOKEN")),
+	}
+}
@@ -2,6 +2,7 @@ package tweets
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -12,9 +13,9 @@ import (
 	//lint:ignore SA1019 deprecated and I don't care
 	"github.com/dghubble/go-twitter/twitter"
 	"github.com/dghubble/oauth1"
+	"github.com/icco/cron/retry"
 	"github.com/icco/cron/shared"
 	gql "github.com/icco/graphql"
-	"github.com/machinebox/graphql"
 	"go.uber.org/zap"
 )
 
@@ -30,19 +31,20 @@ type TwitterAuth struct {
 type Twitter struct {
 	shared.Config
 
-	TwitterAuth  *TwitterAuth
-	GraphQLToken string
+	TwitterAuth *TwitterAuth
+	GraphQL     *shared.GraphQL
 }
 
-// Validate gets a twitter client and the current twitter user.
-func (t *TwitterAuth) Validate(ctx context.Context, log *zap.SugaredLogger) (*twitter.Client, *twitter.User, error) {
+// Validate gets a twitter client that makes requests with cfg's HTTP client,
+// and the current twitter user.
+func (t *TwitterAuth) Validate(ctx context.Context, cfg shared.Config) (*twitter.Client, *twitter.User, error) {
 	if t.ConsumerKey == "" || t.ConsumerSecret == "" || t.AccessToken == "" || t.AccessSecret == "" {
 		return nil, nil, fmt.Errorf("consumer key/secret and Access token/secret required")
 	}
 
 	config := oauth1.NewConfig(t.ConsumerKey, t.ConsumerSecret)
 	token := oauth1.NewToken(t.AccessToken, t.AccessSecret)
-	httpClient := config.Client(ctx, token)
+	httpClient := config.Client(context.WithValue(ctx, oauth1.HTTPClient, cfg.HTTPClient()), token)
 	client := twitter.NewClient(httpClient)
 
 	// Verify Credentials
@@ -52,25 +54,40 @@ func (t *TwitterAuth) Validate(ctx context.Context, log *zap.SugaredLogger) (*tw
 	}
 	user, _, err := client.Accounts.VerifyCredentials(verifyParams)
 	if err != nil {
-		log.Errorw("error verifying creds", zap.Error(err))
+		cfg.Log.Errorw("error verifying creds", zap.Error(err))
 		return nil, nil, err
 	}
 
 	return client, user, nil
 }
 
-// CacophonyCron triggers the cacophony cron.
+const cacophonyURL = "https://cacophony.natwelch.com/cron"
+
+// CacophonyCron triggers the cacophony cron. In a dry run the trigger is
+// recorded instead.
 func (t *Twitter) CacophonyCron(ctx context.Context) error {
-	if _, err := http.Get("https://cacophony.natwelch.com/cron"); err != nil {
+	if t.DryRun != nil {
+		t.DryRun.Record(shared.WriteHTTP, "GET "+cacophonyURL, nil)
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cacophonyURL, nil)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+
+	resp, err := t.HTTPClient().Do(req)
+	if err != nil {
 		return fmt.Errorf("could not trigger cacophony: %w", err)
 	}
+	resp.Body.Close()
 
 	return nil
 }
 
 // SaveUserTweets gets a users timeline and uploads it to graphql.
 func (t *Twitter) SaveUserTweets(ctx context.Context) error {
-	client, user, err := t.TwitterAuth.Validate(ctx, t.Log)
+	client, user, err := t.TwitterAuth.Validate(ctx, t.Config)
 	if err != nil {
 		return err
 	}
@@ -88,7 +105,7 @@ func (t *Twitter) SaveUserTweets(ctx context.Context) error {
 			return err
 		}
 		tm := time.Unix(i, 0)
-		return fmt.Errorf("out of Rate Limit, returns: %+v", tm)
+		return retry.RateLimited(fmt.Errorf("out of Rate Limit, returns: %+v", tm), tm)
 	}
 
 	if err != nil {
@@ -96,11 +113,30 @@ func (t *Twitter) SaveUserTweets(ctx context.Context) error {
 		return err
 	}
 
+	var upserts []shared.Upsert
+	var ids []string
 	for _, tw := range tweets {
-		err := t.UploadTweet(ctx, tw)
+		tweet, err := newTweet(tw)
 		if err != nil {
-			return nil
+			t.Log.Warnw("skipping tweet", "id", tw.IDStr, zap.Error(err))
+			continue
+		}
+		upserts = append(upserts, shared.TweetUpsert(tweet))
+		ids = append(ids, tw.IDStr)
+	}
+
+	err = t.GraphQL.Batch(ctx, upserts)
+	var be *shared.BatchError
+	if errors.As(err, &be) {
+		for _, i := range be.Indexes() {
+			t.Log.Errorw("could not upload tweet", "id", ids[i], zap.Error(be.Failed[i]))
 		}
+		// The rest were saved, and sending these again won't get them
+		// accepted, so they don't fail the run.
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("upload tweets: %w", err)
 	}
 
 	return nil
@@ -122,14 +158,8 @@ func (t *Twitter) CacheRandomTweets(ctx context.Context) error {
   }
   `
 
-	gqlClient := graphql.NewClient("https://graphql.natwelch.com/graphql")
-
-	req := graphql.NewRequest(query)
-	req.Header.Add("X-API-AUTH", t.GraphQLToken)
-	req.Header.Add("User-Agent", "icco-cron/1.0")
-
 	var data tweetids
-	if err := gqlClient.Run(ctx, req, &data); err != nil {
+	if err := t.GraphQL.Do(ctx, query, nil, &data); err != nil {
 		t.Log.Errorw("error talking to graphql", zap.Error(err))
 		return err
 	}
@@ -164,7 +194,7 @@ func (t *Twitter) CacheRandomTweets(ctx context.Context) error {
 
 // GetTweet gets a single tweet.
 func (t *Twitter) GetTweet(ctx context.Context, id int64) (*twitter.Tweet, error) {
-	client, _, err := t.TwitterAuth.Validate(ctx, t.Log)
+	client, _, err := t.TwitterAuth.Validate(ctx, t.Config)
 	if err != nil {
 		return nil, err
 	}
@@ -181,7 +211,7 @@ func (t *Twitter) GetTweet(ctx context.Context, id int64) (*twitter.Tweet, error
 			return nil, err
 		}
 		tm := time.Unix(i, 0)
-		return nil, fmt.Errorf("out of Rate Limit, returns: %+v", tm)
+		return nil, retry.RateLimited(fmt.Errorf("out of Rate Limit, returns: %+v", tm), tm)
 	}
 
 	if err != nil {
@@ -194,6 +224,21 @@ func (t *Twitter) GetTweet(ctx context.Context, id int64) (*twitter.Tweet, error
 
 // UploadTweet uploads a single tweet.
 func (t *Twitter) UploadTweet(ctx context.Context, tw twitter.Tweet) error {
+	tweet, err := newTweet(tw)
+	if err != nil {
+		return err
+	}
+
+	if err := t.GraphQL.UpsertTweet(ctx, tweet); err != nil {
+		t.Log.Errorw("error talking to graphql", zap.Error(err))
+		return fmt.Errorf("upload tweet: %w", err)
+	}
+
+	return nil
+}
+
+// newTweet converts a tweet from the Twitter API to what graphql takes.
+func newTweet(tw twitter.Tweet) (gql.NewTweet, error) {
 	text := tw.FullText
 	if text == "" && tw.Text != "" {
 		text = tw.Text
@@ -217,7 +262,7 @@ func (t *Twitter) UploadTweet(ctx context.Context, tw twitter.Tweet) error {
 
 	tp, err := tw.CreatedAtTime()
 	if err != nil {
-		return err
+		return gql.NewTweet{}, err
 	}
 	tweet.Posted = tp
 
@@ -233,24 +278,5 @@ func (t *Twitter) UploadTweet(ctx context.Context, tw twitter.Tweet) error {
 		tweet.UserMentions[i] = v.ScreenName
 	}
 
-	gqlClient := graphql.NewClient("https://graphql.natwelch.com/graphql")
-	mut := `
-  mutation ($t: NewTweet!) {
-      upsertTweet(input: $t) {
-        id
-      }
-    }
-  `
-
-	req := graphql.NewRequest(mut)
-	req.Var("t", tweet)
-	req.Header.Add("X-API-AUTH", t.GraphQLToken)
-	req.Header.Add("User-Agent", "icco-cron/1.0")
-	err = gqlClient.Run(ctx, req, nil)
-	if err != nil {
-		t.Log.Errorw("error talking to graphql", zap.Error(err))
-		return fmt.Errorf("upload tweet: %w", err)
-	}
-
-	return nil
+	return tweet, nil
 }
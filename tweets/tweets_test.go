@@ -0,0 +1,89 @@
+package tweets
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/icco/cron/gqltest"
+	"github.com/icco/cron/shared"
+	"go.uber.org/zap"
+)
+
+var testSecrets = map[string]string{
+	"TWITTER_CONSUMER_KEY":    "key",
+	"TWITTER_CONSUMER_SECRET": "secret",
+	"TWITTER_ACCESS_TOKEN":    "token",
+	"TWITTER_ACCESS_SECRET":   "secret",
+}
+
+func testServer(t *testing.T) *gqltest.Server {
+	t.Helper()
+
+	srv := gqltest.NewServer(t)
+
+	twitter := http.NewServeMux()
+	twitter.Handle("/1.1/account/verify_credentials.json", gqltest.File(t, "testdata/verify_credentials.json"))
+	twitter.Handle("/1.1/statuses/user_timeline.json", gqltest.File(t, "testdata/user_timeline.json"))
+	twitter.Handle("/1.1/statuses/show.json", gqltest.File(t, "testdata/show.json"))
+	srv.Upstream("api.twitter.com", twitter)
+	srv.Upstream("cacophony.natwelch.com", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	return srv
+}
+
+func TestSaveUserTweets(t *testing.T) {
+	srv := testServer(t)
+	if err := srv.Run(t, "user-tweets", testSecrets); err != nil {
+		t.Fatal(err)
+	}
+
+	gqltest.Golden(t, "testdata/user-tweets.golden.json", srv.Mutations())
+}
+
+func TestSaveUserTweetsRejected(t *testing.T) {
+	srv := testServer(t)
+	srv.Reject("upsertTweet", "1742222222222222222")
+
+	if err := srv.Run(t, "user-tweets", testSecrets); err != nil {
+		t.Fatalf("expected a rejected tweet not to fail the run, got %v", err)
+	}
+	if got := srv.Mutations(); len(got) != 1 {
+		t.Errorf("expected the other tweet to be saved, got %+v", got)
+	}
+}
+
+func TestCacheRandomTweets(t *testing.T) {
+	srv := testServer(t)
+	srv.Respond("homeTimelineURLs", []map[string]any{
+		{"tweetIDs": []string{"1741000000000000000"}},
+	})
+	if err := srv.Run(t, "random-tweets", testSecrets); err != nil {
+		t.Fatal(err)
+	}
+
+	// The job picks ten IDs at random, and there is only one to pick.
+	got := srv.Mutations()
+	if len(got) != 10 {
+		t.Fatalf("expected ten mutations, got %d", len(got))
+	}
+	gqltest.Golden(t, "testdata/random-tweets.golden.json", got[:1])
+}
+
+func TestCacophonyCronDryRun(t *testing.T) {
+	srv := gqltest.NewServer(t)
+	srv.Upstream("cacophony.natwelch.com", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("cacophony was triggered in a dry run")
+	}))
+
+	rec := &shared.Recorder{}
+	tw := &Twitter{Config: shared.Config{Log: zap.NewNop().Sugar(), DryRun: rec}}
+	if err := tw.CacophonyCron(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	w := rec.Writes()
+	if len(w) != 1 || w[0].Kind != shared.WriteHTTP || w[0].Target != "GET https://cacophony.natwelch.com/cron" {
+		t.Errorf("writes = %+v", w)
+	}
+}
@@ -27,8 +27,7 @@ func (cfg *Config) Update(ctx context.Context, site sites.SiteMap) error {
 		return fmt.Errorf("get build trigger %q: %w", name, err)
 	}
 
-	cfg.Log.Debugw("running build trigger", "tigger", trig, "site", site)
-	op, err := c.RunBuildTrigger(ctx, &cloudbuildpb.RunBuildTriggerRequest{
+	runReq := &cloudbuildpb.RunBuildTriggerRequest{
 		ProjectId: cfg.GoogleProject,
 		TriggerId: trig.Id,
 		Source: &cloudbuildpb.RepoSource{
@@ -38,7 +37,14 @@ func (cfg *Config) Update(ctx context.Context, site sites.SiteMap) error {
 				BranchName: site.Branch,
 			},
 		},
-	})
+	}
+	if cfg.DryRun != nil {
+		cfg.record("RunBuildTrigger", name, runReq)
+		return nil
+	}
+
+	cfg.Log.Debugw("running build trigger", "tigger", trig, "site", site)
+	op, err := c.RunBuildTrigger(ctx, runReq)
 	if err != nil {
 		return fmt.Errorf("run build trigger %q: %w", name, err)
 	}
@@ -0,0 +1,61 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/retry"
+	"github.com/icco/cron/sites"
+)
+
+func init() {
+	jobs.Register(jobs.New(
+		"update",
+		"Rebuilds and deploys a site, picked at random unless one is given.",
+		func(ctx context.Context, cfg *jobs.Config) error {
+			c := &Config{Config: cfg.Config, GoogleProject: cfg.Project}
+
+			name := cfg.Args["site"]
+			if name == "" {
+				return c.UpdateRandomSite(ctx)
+			}
+
+			s, ok := sites.Find(name)
+			if !ok {
+				return retry.Permanent(fmt.Errorf("unknown site %q", name))
+			}
+
+			return c.Update(ctx, s)
+		},
+		jobs.WithArgs(jobs.Arg{Name: "site", Description: "Deployment name of the site to update, like gotak.", Validate: validateSite}),
+		jobs.WithConcurrency(jobs.Allow, 3),
+		jobs.WithRetry(retry.Never),
+		jobs.WithSchedule("0 4 * * *"),
+	))
+
+	jobs.Register(jobs.New(
+		"update-triggers",
+		"Syncs the Cloud Build triggers for every site.",
+		func(ctx context.Context, cfg *jobs.Config) error {
+			c := &Config{Config: cfg.Config, GoogleProject: cfg.Project}
+			return c.UpdateTriggers(ctx)
+		},
+		jobs.WithConcurrency(jobs.Queue, 1),
+		jobs.WithSchedule("0 3 * * *"),
+	))
+}
+
+// validateSite checks that s is the deployment name of a site. Empty means a
+// site picked at random.
+func validateSite(s string) error {
+	if s == "" {
+		return nil
+	}
+
+	if _, ok := sites.Find(s); !ok {
+		return fmt.Errorf("%q is not a site in sites.All", s)
+	}
+
+	return nil
+}
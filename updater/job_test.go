@@ -0,0 +1,42 @@
+package updater
+
+import (
+	"context"
+	"testing"
+
+	"github.com/icco/cron/jobs"
+	"github.com/icco/cron/retry"
+	"github.com/icco/cron/shared"
+	"go.uber.org/zap"
+)
+
+func TestUpdateSiteArg(t *testing.T) {
+	j, ok := jobs.Get("update")
+	if !ok {
+		t.Fatal("update is not registered")
+	}
+
+	for _, tc := range []struct {
+		site    string
+		wantErr bool
+	}{
+		{"gotak", false},
+		{"nope", true},
+		{"", false},
+	} {
+		err := jobs.ValidateArgs(j, jobs.Args{"site": tc.site})
+		if (err != nil) != tc.wantErr {
+			t.Errorf("site %q: expected error %v, got %+v", tc.site, tc.wantErr, err)
+		}
+	}
+
+	// Messages are validated before they run, but a job run directly with
+	// an unknown site still fails for good.
+	err := j.Run(context.Background(), &jobs.Config{
+		Config: shared.Config{Log: zap.NewNop().Sugar()},
+		Args:   jobs.Args{"site": "nope"},
+	})
+	if retry.ClassOf(err) != retry.ClassPermanent {
+		t.Errorf("expected a permanent error for an unknown site, got %+v", err)
+	}
+}
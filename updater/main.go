@@ -1,6 +1,12 @@
 package updater
 
-import "github.com/icco/cron/shared"
+import (
+	"encoding/json"
+
+	"github.com/icco/cron/shared"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
 
 // Config is a config.
 type Config struct {
@@ -8,3 +14,13 @@ type Config struct {
 
 	GoogleProject string
 }
+
+// record notes a Cloud Build call that a dry run skips.
+func (cfg *Config) record(method, trigger string, req proto.Message) {
+	var payload any = req
+	if b, err := protojson.Marshal(req); err == nil {
+		payload = json.RawMessage(b)
+	}
+
+	cfg.DryRun.Record(shared.WriteCloudBuild, method+" "+trigger, payload)
+}
@@ -118,6 +118,11 @@ func (cfg *Config) upsertBuildTrigger(ctx context.Context, c *cloudbuild.Client,
 	}
 
 	if existingTriggerID == "" {
+		if cfg.DryRun != nil {
+			cfg.record("CreateBuildTrigger", createReq.Trigger.Name, createReq)
+			return nil
+		}
+
 		cfg.Log.Infow("creating build trigger", "request", createReq, "site", s)
 		if _, err := c.CreateBuildTrigger(ctx, createReq); err != nil {
 			return fmt.Errorf("could not create trigger %+v: %w", createReq, err)
@@ -132,6 +137,11 @@ func (cfg *Config) upsertBuildTrigger(ctx context.Context, c *cloudbuild.Client,
 		Trigger:   createReq.Trigger,
 	}
 
+	if cfg.DryRun != nil {
+		cfg.record("UpdateBuildTrigger", createReq.Trigger.Name, updateReq)
+		return nil
+	}
+
 	cfg.Log.Debugw("updating build trigger", "request", updateReq, "site", s)
 	if _, err := c.UpdateBuildTrigger(ctx, updateReq); err != nil {
 		return fmt.Errorf("could not update trigger %+v: %w", updateReq, err)
@@ -245,6 +255,11 @@ func (cfg *Config) upsertDeployTrigger(ctx context.Context, c *cloudbuild.Client
 	}
 
 	if existingTriggerID == "" {
+		if cfg.DryRun != nil {
+			cfg.record("CreateBuildTrigger", createReq.Trigger.Name, createReq)
+			return nil
+		}
+
 		cfg.Log.Infow("creating deploy trigger", "request", createReq, "site", s)
 		if _, err := c.CreateBuildTrigger(ctx, createReq); err != nil {
 			return fmt.Errorf("could not create trigger %+v: %w", createReq, err)
@@ -259,6 +274,11 @@ func (cfg *Config) upsertDeployTrigger(ctx context.Context, c *cloudbuild.Client
 		Trigger:   createReq.Trigger,
 	}
 
+	if cfg.DryRun != nil {
+		cfg.record("UpdateBuildTrigger", createReq.Trigger.Name, updateReq)
+		return nil
+	}
+
 	cfg.Log.Infow("updating deploy trigger", "request", updateReq, "site", s)
 	if _, err := c.UpdateBuildTrigger(ctx, updateReq); err != nil {
 		return fmt.Errorf("could not update trigger %+v: %w", updateReq, err)